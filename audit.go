@@ -0,0 +1,210 @@
+package gravita
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditArguments specifies how the arguments of an invocation are recorded in an AuditRecord
+type AuditArguments int
+
+const (
+	// AuditArgumentsOmit does not record arguments
+	AuditArgumentsOmit AuditArguments = iota
+	// AuditArgumentsHashed records the HMAC-SHA256 of each argument value keyed by Mux.AuditHashKey,
+	// or the plain SHA-256 hash if the key is not set. Note that a plain hash of low-entropy values such as emails
+	// is reversed by a dictionary lookup, so that set the key for personal data.
+	AuditArgumentsHashed
+	// AuditArgumentsRedacted records only the shape of arguments, each non-null value is replaced
+	AuditArgumentsRedacted
+	// AuditArgumentsRaw records arguments as is
+	AuditArgumentsRaw
+)
+
+// RedactedValue is the placeholder of a redacted argument value
+const RedactedValue = "[REDACTED]"
+
+// AuditRecord represents a single audit log entry of a LambdaUDF invocation
+type AuditRecord struct {
+	Time                   time.Time `json:"time"`
+	LambdaUDFEventMetadata `json:",inline"`
+
+	Success    bool            `json:"success"`
+	ErrorMsg   string          `json:"error_msg,omitempty"`
+	DurationMs int64           `json:"duration_ms"`
+	Arguments  [][]interface{} `json:"arguments,omitempty"`
+	// Warning is set for invocations of deprecated entries
+	Warning string `json:"warning,omitempty"`
+}
+
+// AuditSink is the destination of AuditRecord
+type AuditSink interface {
+	WriteAuditRecord(context.Context, *AuditRecord) error
+}
+
+// AuditSinkFunc is a type of function that satisfies AuditSink
+type AuditSinkFunc func(context.Context, *AuditRecord) error
+
+func (f AuditSinkFunc) WriteAuditRecord(ctx context.Context, record *AuditRecord) error {
+	return f(ctx, record)
+}
+
+// JSONLinesAuditSink is an AuditSink that writes one JSON object per line
+type JSONLinesAuditSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONLinesAuditSink returns an AuditSink that writes AuditRecord to w as JSON lines
+func NewJSONLinesAuditSink(w io.Writer) *JSONLinesAuditSink {
+	return &JSONLinesAuditSink{
+		w: w,
+	}
+}
+
+// NewFileAuditSink returns an AuditSink that appends AuditRecord to the file at path as JSON lines
+func NewFileAuditSink(path string) (*JSONLinesAuditSink, error) {
+	fp, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLinesAuditSink{
+		w:      fp,
+		closer: fp,
+	}, nil
+}
+
+func (s *JSONLinesAuditSink) WriteAuditRecord(_ context.Context, record *AuditRecord) error {
+	bs, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	bs = append(bs, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(bs)
+	return err
+}
+
+// Close closes the underlying file, if the sink was created by NewFileAuditSink
+func (s *JSONLinesAuditSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+var (
+	defaultAuditSinkOnce sync.Once
+	defaultAuditSink     AuditSink
+)
+
+func getDefaultAuditSink() AuditSink {
+	defaultAuditSinkOnce.Do(func() {
+		defaultAuditSink = NewJSONLinesAuditSink(os.Stdout)
+	})
+	return defaultAuditSink
+}
+
+// Audit enables audit logging of invocations that match this Entry.
+// Records are written to Mux.AuditSink, or to stdout as JSON lines if it is not set.
+// Invocations whose handler panics are also recorded before the panic is propagated.
+// Auditing is fail-closed: if the AuditSink fails, HandleLambdaEvent returns the error instead of the results,
+// even though the handler has already been executed.
+func (e *Entry) Audit(args AuditArguments) *Entry {
+	return e.update(func(s *entryState) {
+		s.audit = &args
+	})
+}
+
+// auditPanic writes the audit record of an invocation whose handler panicked, and propagates the panic.
+// It must be called by defer.
+func (mux *Mux) auditPanic(ctx context.Context, args AuditArguments, event *LambdaUDFEvent, startAt time.Time, warning string) {
+	panicValue := recover()
+	if panicValue == nil {
+		return
+	}
+	output := &lambdaUDFOutputData{
+		Success:  false,
+		ErrorMsg: fmt.Sprintf("panic: %v", panicValue),
+	}
+	if err := mux.writeAuditRecord(ctx, args, event, output, startAt, warning); err != nil {
+		mux.logf("[warn] gravita: failed to write audit record of panicked invocation query_id=%d request_id=%s: %v", event.QueryID, event.RequestID, err)
+	}
+	panic(panicValue)
+}
+
+func (mux *Mux) writeAuditRecord(ctx context.Context, args AuditArguments, event *LambdaUDFEvent, output *lambdaUDFOutputData, startAt time.Time, warning string) error {
+	record := &AuditRecord{
+		Time:                   startAt,
+		LambdaUDFEventMetadata: event.LambdaUDFEventMetadata,
+		Success:                output.Success,
+		ErrorMsg:               output.ErrorMsg,
+		DurationMs:             time.Since(startAt).Milliseconds(),
+		Arguments:              auditArguments(args, event.Arguments, mux.auditHashKey()),
+		Warning:                warning,
+	}
+	sink := mux.auditSink()
+	if sink == nil {
+		sink = getDefaultAuditSink()
+	}
+	if err := sink.WriteAuditRecord(ctx, record); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	return nil
+}
+
+func auditArguments(mode AuditArguments, args [][]interface{}, key []byte) [][]interface{} {
+	switch mode {
+	case AuditArgumentsRaw:
+		return args
+	case AuditArgumentsHashed:
+		return mapArguments(args, func(v interface{}) interface{} {
+			return hashValue(key, v)
+		})
+	case AuditArgumentsRedacted:
+		return mapArguments(args, func(interface{}) interface{} {
+			return RedactedValue
+		})
+	default:
+		return nil
+	}
+}
+
+func mapArguments(args [][]interface{}, f func(interface{}) interface{}) [][]interface{} {
+	ret := make([][]interface{}, len(args))
+	for i, rowArgs := range args {
+		row := make([]interface{}, len(rowArgs))
+		for j, v := range rowArgs {
+			if v != nil {
+				row[j] = f(v)
+			}
+		}
+		ret[i] = row
+	}
+	return ret
+}
+
+// hashValue returns the HMAC-SHA256 of the value keyed by key, or the SHA-256 hash if key is empty
+func hashValue(key []byte, v interface{}) interface{} {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		bs = []byte(fmt.Sprint(v))
+	}
+	if len(key) > 0 {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(bs)
+		return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+	}
+	sum := sha256.Sum256(bs)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,141 @@
+package gravita_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudit(t *testing.T) {
+	cases := []struct {
+		casename string
+		mode     gravita.AuditArguments
+		fail     bool
+		expected string
+	}{
+		{
+			casename: "omit",
+			mode:     gravita.AuditArgumentsOmit,
+			expected: `{"success":true}`,
+		},
+		{
+			casename: "raw",
+			mode:     gravita.AuditArgumentsRaw,
+			expected: `{"success":true,"arguments":[["hoge",1],["fuga",null]]}`,
+		},
+		{
+			casename: "redacted",
+			mode:     gravita.AuditArgumentsRedacted,
+			expected: `{"success":true,"arguments":[["[REDACTED]","[REDACTED]"],["[REDACTED]",null]]}`,
+		},
+		{
+			casename: "failure",
+			mode:     gravita.AuditArgumentsOmit,
+			fail:     true,
+			expected: `{"success":false,"error_msg":"invalid"}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.casename, func(t *testing.T) {
+			var buf bytes.Buffer
+			mux := gravita.NewMux()
+			mux.AuditSink = gravita.NewJSONLinesAuditSink(&buf)
+			mux.HandleRowFunc("pii_udf", func(_ context.Context, args []interface{}) (interface{}, error) {
+				if c.fail {
+					return nil, errors.New("invalid")
+				}
+				return fmt.Sprint(args...), nil
+			}).Audit(c.mode)
+			mux.HandleRowFunc("*", func(_ context.Context, args []interface{}) (interface{}, error) {
+				return fmt.Sprint(args...), nil
+			})
+			_, err := mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("other_udf", [][]interface{}{{"hoge", 1}}))
+			require.NoError(t, err)
+			require.Empty(t, buf.String(), "not audited entry")
+
+			_, err = mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("pii_udf", [][]interface{}{{"hoge", 1}, {"fuga", nil}}))
+			require.NoError(t, err)
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			require.Len(t, lines, 1)
+			var record map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+			require.Equal(t, "pii_udf", record["external_function"])
+			require.Equal(t, "test", record["user"])
+			require.Equal(t, "dummy", record["cluster"])
+			require.EqualValues(t, 10, record["query_id"])
+			require.EqualValues(t, 2, record["num_records"])
+			for _, key := range []string{"time", "duration_ms", "request_id", "database", "external_function", "user", "cluster", "query_id", "num_records"} {
+				delete(record, key)
+			}
+			bs, err := json.Marshal(record)
+			require.NoError(t, err)
+			require.JSONEq(t, c.expected, string(bs))
+		})
+	}
+}
+
+func TestAuditHashed(t *testing.T) {
+	var records []*gravita.AuditRecord
+	mux := gravita.NewMux()
+	mux.AuditSink = gravita.AuditSinkFunc(func(_ context.Context, record *gravita.AuditRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	mux.HandleRowFunc("*", func(_ context.Context, args []interface{}) (interface{}, error) {
+		return nil, nil
+	}).Audit(gravita.AuditArgumentsHashed)
+	_, err := mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("pii_udf", [][]interface{}{{"hoge"}, {"hoge"}, {"fuga"}}))
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	args := records[0].Arguments
+	require.Equal(t, args[0][0], args[1][0])
+	require.NotEqual(t, args[0][0], args[2][0])
+	require.True(t, strings.HasPrefix(args[0][0].(string), "sha256:"))
+
+	records = nil
+	mux.AuditHashKey = []byte("secret")
+	_, err = mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("pii_udf", [][]interface{}{{"hoge"}}))
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	keyed := records[0].Arguments[0][0].(string)
+	require.True(t, strings.HasPrefix(keyed, "hmac-sha256:"))
+	require.NotEqual(t, strings.TrimPrefix(args[0][0].(string), "sha256:"), strings.TrimPrefix(keyed, "hmac-sha256:"))
+}
+
+func TestAuditSinkError(t *testing.T) {
+	mux := gravita.NewMux()
+	mux.AuditSink = gravita.AuditSinkFunc(func(_ context.Context, _ *gravita.AuditRecord) error {
+		return errors.New("disk full")
+	})
+	mux.HandleRowFunc("*", func(_ context.Context, args []interface{}) (interface{}, error) {
+		return nil, nil
+	}).Audit(gravita.AuditArgumentsOmit)
+	_, err := mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("pii_udf", [][]interface{}{{"hoge"}}))
+	require.EqualError(t, err, "audit: disk full")
+}
+
+func TestAuditPanic(t *testing.T) {
+	var records []*gravita.AuditRecord
+	mux := gravita.NewMux()
+	mux.AuditSink = gravita.AuditSinkFunc(func(_ context.Context, record *gravita.AuditRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	mux.HandleFunc("test_udf", func(_ context.Context, _ [][]interface{}) ([]interface{}, error) {
+		panic(errors.New("boom"))
+	}).Audit(gravita.AuditArgumentsOmit)
+
+	_, err := mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("test_udf", [][]interface{}{{1}}))
+	require.EqualError(t, err, "boom")
+	require.Len(t, records, 1)
+	require.False(t, records[0].Success)
+	require.Equal(t, "panic: boom", records[0].ErrorMsg)
+	require.Equal(t, "test_udf", records[0].ExternalFunction)
+}
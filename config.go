@@ -0,0 +1,325 @@
+package gravita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HandlerRegistry holds the handlers and middlewares referenced by name from a routing Config
+type HandlerRegistry struct {
+	handlers    map[string]LambdaUDFHandler
+	rowHandlers map[string]LambdaUDFRowHandler
+	middlewares map[string]Middleware
+}
+
+func NewHandlerRegistry() *HandlerRegistry {
+	return &HandlerRegistry{
+		handlers:    make(map[string]LambdaUDFHandler),
+		rowHandlers: make(map[string]LambdaUDFRowHandler),
+		middlewares: make(map[string]Middleware),
+	}
+}
+
+// Register registers a LambdaUDFHandler referenced by `handler` in the Config
+func (r *HandlerRegistry) Register(name string, handler LambdaUDFHandler) *HandlerRegistry {
+	r.handlers[name] = handler
+	return r
+}
+
+func (r *HandlerRegistry) RegisterFunc(name string, f func(context.Context, [][]interface{}) ([]interface{}, error)) *HandlerRegistry {
+	return r.Register(name, LambdaUDFHandlerFunc(f))
+}
+
+// RegisterRow registers a LambdaUDFRowHandler referenced by `row_handler` in the Config
+func (r *HandlerRegistry) RegisterRow(name string, handler LambdaUDFRowHandler) *HandlerRegistry {
+	r.rowHandlers[name] = handler
+	return r
+}
+
+func (r *HandlerRegistry) RegisterRowFunc(name string, f func(context.Context, []interface{}) (interface{}, error)) *HandlerRegistry {
+	return r.RegisterRow(name, LambdaUDFRowHandlerFunc(f))
+}
+
+// RegisterMiddleware registers a Middleware referenced by `middlewares` in the Config
+func (r *HandlerRegistry) RegisterMiddleware(name string, m Middleware) *HandlerRegistry {
+	r.middlewares[name] = m
+	return r
+}
+
+func (r *HandlerRegistry) lookupHandler(name string) (LambdaUDFHandler, error) {
+	if h, ok := r.handlers[name]; ok {
+		return h, nil
+	}
+	return nil, fmt.Errorf("unknown handler `%s` (registered: %s)", name, registeredNames(r.handlers))
+}
+
+func (r *HandlerRegistry) lookupRowHandler(name string) (LambdaUDFRowHandler, error) {
+	if h, ok := r.rowHandlers[name]; ok {
+		return h, nil
+	}
+	return nil, fmt.Errorf("unknown row handler `%s` (registered: %s)", name, registeredNames(r.rowHandlers))
+}
+
+func (r *HandlerRegistry) lookupMiddleware(name string) (Middleware, error) {
+	if m, ok := r.middlewares[name]; ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown middleware `%s` (registered: %s)", name, registeredNames(r.middlewares))
+}
+
+func registeredNames(m interface{}) string {
+	var names []string
+	switch m := m.(type) {
+	case map[string]LambdaUDFHandler:
+		for name := range m {
+			names = append(names, name)
+		}
+	case map[string]LambdaUDFRowHandler:
+		for name := range m {
+			names = append(names, name)
+		}
+	case map[string]Middleware:
+		for name := range m {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Config is a routing file that describes the entries of a Mux.
+// Both YAML and JSON are accepted, and unknown keys are errors.
+//
+//	most_specific_match: true
+//	middlewares: [logging]
+//	not_match_handler: not_found
+//	entries:
+//	  - name: mask
+//	    external_function: "*mask*"
+//	    row_handler: mask
+//	    max_concurrency: 8
+//	  - name: geocode
+//	    external_function: geocode
+//	    handler: geocode
+//	    batch:
+//	      size: 100
+//	      distinct: true
+//	      max_concurrency: 4
+type Config struct {
+	MostSpecificMatch bool `yaml:"most_specific_match" json:"most_specific_match"`
+	CaseInsensitive   bool `yaml:"case_insensitive" json:"case_insensitive"`
+	// Middlewares are names of middlewares applied to all entries, see Mux.Use
+	Middlewares     []string      `yaml:"middlewares" json:"middlewares"`
+	NotMatchHandler string        `yaml:"not_match_handler" json:"not_match_handler"`
+	Entries         []EntryConfig `yaml:"entries" json:"entries"`
+}
+
+// EntryConfig describes an Entry. Exactly one of Handler and RowHandler is required.
+type EntryConfig struct {
+	Name                   string `yaml:"name" json:"name"`
+	ExternalFunction       string `yaml:"external_function" json:"external_function"`
+	ExternalFunctionRegexp string `yaml:"external_function_regexp" json:"external_function_regexp"`
+	User                   string `yaml:"user" json:"user"`
+	UserRegexp             string `yaml:"user_regexp" json:"user_regexp"`
+	Cluster                string `yaml:"cluster" json:"cluster"`
+	ClusterRegexp          string `yaml:"cluster_regexp" json:"cluster_regexp"`
+	Database               string `yaml:"database" json:"database"`
+	DatabaseRegexp         string `yaml:"database_regexp" json:"database_regexp"`
+	Priority               int    `yaml:"priority" json:"priority"`
+	// Handler is the name of a LambdaUDFHandler in the HandlerRegistry
+	Handler string `yaml:"handler" json:"handler"`
+	// RowHandler is the name of a LambdaUDFRowHandler in the HandlerRegistry, processed by ParallelRowProcessHandler
+	RowHandler string `yaml:"row_handler" json:"row_handler"`
+	// MaxConcurrency is the max number of rows processed at the same time by RowHandler. 0 means unlimited
+	MaxConcurrency int `yaml:"max_concurrency" json:"max_concurrency"`
+	// Batch wraps the handler with BatchProcessHandler
+	Batch *BatchConfig `yaml:"batch" json:"batch"`
+	// Middlewares are names of middlewares applied to the handler of this entry only, see Entry.Use
+	Middlewares []string `yaml:"middlewares" json:"middlewares"`
+}
+
+// BatchConfig is the settings of BatchProcessHandler
+type BatchConfig struct {
+	Size          int  `yaml:"size" json:"size"`
+	MaxBatchCount int  `yaml:"max_batch_count" json:"max_batch_count"`
+	Distinct      bool `yaml:"distinct" json:"distinct"`
+	// MaxConcurrency is the max number of batches processed at the same time. 0 means unlimited
+	MaxConcurrency int `yaml:"max_concurrency" json:"max_concurrency"`
+}
+
+// LoadConfig reads the routing file from path
+func LoadConfig(path string) (*Config, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	cfg, err := DecodeConfig(fp)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// DecodeConfig reads the routing file from r
+func DecodeConfig(r io.Reader) (*Config, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("config is empty")
+		}
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// LoadMux reads the routing file from path and builds a validated Mux
+func LoadMux(path string, registry *HandlerRegistry) (*Mux, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	mux, err := cfg.Build(registry)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return mux, nil
+}
+
+// Build creates a Mux from the Config with the handlers in the registry.
+// All problems, such as unknown handler names and invalid patterns, are reported at once.
+func (cfg *Config) Build(registry *HandlerRegistry) (*Mux, error) {
+	var msgs []string
+	mux := NewMux()
+	mux.MostSpecificMatch = cfg.MostSpecificMatch
+	mux.CaseInsensitive(cfg.CaseInsensitive)
+	for _, name := range cfg.Middlewares {
+		m, err := registry.lookupMiddleware(name)
+		if err != nil {
+			msgs = append(msgs, "middlewares: "+err.Error())
+			continue
+		}
+		mux.Use(m)
+	}
+	if cfg.NotMatchHandler != "" {
+		h, err := registry.lookupHandler(cfg.NotMatchHandler)
+		if err != nil {
+			msgs = append(msgs, "not_match_handler: "+err.Error())
+		}
+		mux.NotMatchHandler = h
+	}
+	for i, ec := range cfg.Entries {
+		label := fmt.Sprintf("entries[%d]", i)
+		if ec.Name != "" {
+			label = fmt.Sprintf("entries[%d] %q", i, ec.Name)
+		}
+		entry, errs := ec.build(registry)
+		for _, err := range errs {
+			msgs = append(msgs, fmt.Sprintf("%s: %v", label, err))
+		}
+		if entry != nil {
+			mux.AddEntry(entry)
+		}
+	}
+	if len(msgs) > 0 {
+		return nil, fmt.Errorf("invalid config: %s", strings.Join(msgs, ", "))
+	}
+	if err := mux.Validate(); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+func (ec EntryConfig) build(registry *HandlerRegistry) (*Entry, []error) {
+	var errs []error
+	entry := NewEntry().Name(ec.Name).Priority(ec.Priority)
+	matchers := []struct {
+		value      string
+		newMatcher func(string) (Matcher, error)
+	}{
+		{ec.ExternalFunction, NewExternalFunctionMatcher},
+		{ec.ExternalFunctionRegexp, NewExternalFunctionRegexpMatcher},
+		{ec.User, NewUserMatcher},
+		{ec.UserRegexp, NewUserRegexpMatcher},
+		{ec.Cluster, NewClusterMatcher},
+		{ec.ClusterRegexp, NewClusterRegexpMatcher},
+		{ec.Database, NewDatabaseMatcher},
+		{ec.DatabaseRegexp, NewDatabaseRegexpMatcher},
+	}
+	for _, m := range matchers {
+		if m.value != "" {
+			entry.MatcherOrError(m.newMatcher(m.value))
+		}
+	}
+	if err := entry.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	var handler LambdaUDFHandler
+	switch {
+	case ec.Handler != "" && ec.RowHandler != "":
+		errs = append(errs, errors.New("both handler and row_handler are specified"))
+	case ec.Handler != "":
+		h, err := registry.lookupHandler(ec.Handler)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		handler = h
+	case ec.RowHandler != "":
+		h, err := registry.lookupRowHandler(ec.RowHandler)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		handler = ParallelRowProcessHandler{RowHandler: h, MaxConcurrency: ec.MaxConcurrency}
+	default:
+		errs = append(errs, errors.New("handler or row_handler is required"))
+	}
+	if ec.MaxConcurrency < 0 {
+		errs = append(errs, fmt.Errorf("max_concurrency must not be negative, got %d", ec.MaxConcurrency))
+	}
+	if ec.MaxConcurrency != 0 && ec.RowHandler == "" {
+		errs = append(errs, errors.New("max_concurrency requires row_handler, use batch.max_concurrency for handler"))
+	}
+	if ec.Batch != nil {
+		if ec.Batch.Size <= 0 {
+			errs = append(errs, fmt.Errorf("batch.size must be positive, got %d", ec.Batch.Size))
+		}
+		if ec.Batch.MaxBatchCount < 0 {
+			errs = append(errs, fmt.Errorf("batch.max_batch_count must not be negative, got %d", ec.Batch.MaxBatchCount))
+		}
+		if ec.Batch.MaxConcurrency < 0 {
+			errs = append(errs, fmt.Errorf("batch.max_concurrency must not be negative, got %d", ec.Batch.MaxConcurrency))
+		}
+		batch := NewBatchProcessHandler(ec.Batch.Size, handler)
+		batch.Distinct(ec.Batch.Distinct)
+		if ec.Batch.MaxBatchCount > 0 {
+			batch.MaxBatchCount(ec.Batch.MaxBatchCount)
+		}
+		batch.MaxConcurrency(ec.Batch.MaxConcurrency)
+		handler = batch
+	}
+	for _, name := range ec.Middlewares {
+		m, err := registry.lookupMiddleware(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		entry.Use(m)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return entry.Handler(handler), nil
+}
@@ -0,0 +1,154 @@
+package gravita_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func testHandlerRegistry() *gravita.HandlerRegistry {
+	return gravita.NewHandlerRegistry().
+		RegisterRowFunc("mask", func(_ context.Context, args []interface{}) (interface{}, error) {
+			return "***", nil
+		}).
+		RegisterFunc("concat", func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+			results := make([]interface{}, 0, len(args))
+			for _, arg := range args {
+				results = append(results, fmt.Sprint(arg...))
+			}
+			return results, nil
+		}).
+		RegisterFunc("not_found", func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+			return nil, fmt.Errorf("not found")
+		}).
+		RegisterMiddleware("upper", func(next gravita.LambdaUDFHandler) gravita.LambdaUDFHandler {
+			return gravita.LambdaUDFHandlerFunc(func(ctx context.Context, args [][]interface{}) ([]interface{}, error) {
+				results, err := next.ExecuteUDF(ctx, args)
+				if err != nil {
+					return nil, err
+				}
+				for i, r := range results {
+					results[i] = strings.ToUpper(r.(string))
+				}
+				return results, nil
+			})
+		})
+}
+
+func TestLoadMux(t *testing.T) {
+	mux, err := gravita.LoadMux("testdata/config.yaml", testHandlerRegistry())
+	require.NoError(t, err)
+	require.True(t, mux.MostSpecificMatch)
+	entries := mux.Entries()
+	require.Equal(t, map[string]string{gravita.SettingMaxConcurrency: "4"}, entries[0].Settings)
+	require.Equal(t, "2", entries[1].Settings[gravita.SettingMaxConcurrency])
+
+	cases := []struct {
+		exFunc   string
+		args     [][]interface{}
+		expected string
+	}{
+		{
+			exFunc:   "mask_email",
+			args:     [][]interface{}{{"a@example.com"}},
+			expected: `{"success":true,"num_records":1,"results":["***"]}`,
+		},
+		{
+			exFunc:   "concat",
+			args:     [][]interface{}{{"a", 1}, {"b", 2}, {"a", 1}},
+			expected: `{"success":true,"num_records":3,"results":["A1","B2","A1"]}`,
+		},
+		{
+			exFunc:   "unknown",
+			args:     [][]interface{}{{1}},
+			expected: `{"success":false,"error_msg":"not found"}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.exFunc, func(t *testing.T) {
+			actual, err := mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent(c.exFunc, c.args))
+			require.NoError(t, err)
+			require.JSONEq(t, c.expected, actual)
+		})
+	}
+}
+
+func TestConfigMiddlewaresWithEnvSettings(t *testing.T) {
+	os.Setenv("GRAVITA_CONCAT_BATCH_SIZE", "1")
+	defer os.Unsetenv("GRAVITA_CONCAT_BATCH_SIZE")
+	config := "entries:\n  - name: concat\n    external_function: concat\n    handler: concat\n    middlewares: [upper]\n    batch:\n      size: 10\n"
+	cfg, err := gravita.DecodeConfig(strings.NewReader(config))
+	require.NoError(t, err)
+	mux, err := cfg.Build(testHandlerRegistry())
+	require.NoError(t, err)
+	require.NoError(t, mux.ApplyEnvSettings())
+	require.Equal(t, "1", mux.Entries()[0].Settings[gravita.SettingBatchSize])
+
+	actual, err := mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("concat", [][]interface{}{{"a", 1}, {"b", 2}}))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"success":true,"num_records":2,"results":["A1","B2"]}`, actual)
+}
+
+func TestConfigBuildErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   string
+		expected string
+	}{
+		{
+			name:     "unknown key",
+			config:   "entries:\n  - external_funtion: foo\n    handler: concat\n",
+			expected: "field external_funtion not found",
+		},
+		{
+			name:     "unknown handler",
+			config:   "entries:\n  - name: foo\n    external_function: foo\n    handler: bar\n",
+			expected: "invalid config: entries[0] \"foo\": unknown handler `bar` (registered: concat, not_found)",
+		},
+		{
+			name:     "unknown middleware",
+			config:   "middlewares: [lower]\nentries:\n  - external_function: foo\n    row_handler: mask\n",
+			expected: "invalid config: middlewares: unknown middleware `lower` (registered: upper)",
+		},
+		{
+			name:     "no handler",
+			config:   "entries:\n  - external_function: foo\n",
+			expected: "invalid config: entries[0]: handler or row_handler is required",
+		},
+		{
+			name:     "invalid pattern and batch size",
+			config:   "entries:\n  - external_function: \"[foo\"\n    handler: concat\n    batch:\n      size: 0\n",
+			expected: "invalid config: entries[0]: glob `[foo`: unterminated character class, entries[0]: batch.size must be positive, got 0",
+		},
+		{
+			name:     "negative max_concurrency",
+			config:   "entries:\n  - external_function: foo\n    row_handler: mask\n    max_concurrency: -1\n  - external_function: bar\n    handler: concat\n    batch:\n      size: 1\n      max_concurrency: -1\n",
+			expected: "invalid config: entries[0]: max_concurrency must not be negative, got -1, entries[1]: batch.max_concurrency must not be negative, got -1",
+		},
+		{
+			name:     "max_concurrency without row_handler",
+			config:   "entries:\n  - external_function: foo\n    handler: concat\n    max_concurrency: 2\n",
+			expected: "invalid config: entries[0]: max_concurrency requires row_handler, use batch.max_concurrency for handler",
+		},
+		{
+			name:     "JSON",
+			config:   `{"entries": [{"external_function": "foo", "handler": "concat", "row_handler": "mask"}]}`,
+			expected: "invalid config: entries[0]: both handler and row_handler are specified",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg, err := gravita.DecodeConfig(strings.NewReader(c.config))
+			if err == nil {
+				_, err = cfg.Build(testHandlerRegistry())
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.expected)
+		})
+	}
+}
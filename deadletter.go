@@ -0,0 +1,141 @@
+package gravita
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DeadLetter represents a failed invocation
+type DeadLetter struct {
+	Time     time.Time       `json:"time"`
+	Event    *LambdaUDFEvent `json:"event"`
+	ErrorMsg string          `json:"error_msg"`
+}
+
+// DeadLetterSink is the destination of DeadLetter
+type DeadLetterSink interface {
+	PutDeadLetter(context.Context, *DeadLetter) error
+}
+
+// DeadLetterSinkFunc is a type of function that satisfies DeadLetterSink
+type DeadLetterSinkFunc func(context.Context, *DeadLetter) error
+
+func (f DeadLetterSinkFunc) PutDeadLetter(ctx context.Context, letter *DeadLetter) error {
+	return f(ctx, letter)
+}
+
+// DirectoryDeadLetterSink is a DeadLetterSink that writes each DeadLetter as a JSON file in a local directory
+type DirectoryDeadLetterSink struct {
+	dir string
+}
+
+// NewDirectoryDeadLetterSink returns a DeadLetterSink that writes to dir, creating it if not exists
+func NewDirectoryDeadLetterSink(dir string) (*DirectoryDeadLetterSink, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DirectoryDeadLetterSink{
+		dir: dir,
+	}, nil
+}
+
+func (s *DirectoryDeadLetterSink) PutDeadLetter(_ context.Context, letter *DeadLetter) error {
+	bs, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	pattern := fmt.Sprintf("%s_%d_*.json", letter.Time.UTC().Format("20060102T150405.000000000"), letter.Event.QueryID)
+	fp, err := os.CreateTemp(s.dir, pattern)
+	if err != nil {
+		return err
+	}
+	if _, err := fp.Write(bs); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
+
+// LoadDeadLetters reads DeadLetters written by DirectoryDeadLetterSink, in order of occurrence
+func LoadDeadLetters(dir string) ([]*DeadLetter, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+	letters := make([]*DeadLetter, 0, len(names))
+	for _, name := range names {
+		bs, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(bs, &letter); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		letters = append(letters, &letter)
+	}
+	return letters, nil
+}
+
+func (mux *Mux) putDeadLetter(ctx context.Context, event *LambdaUDFEvent, errorMsg string) {
+	sink := mux.deadLetterSink()
+	if sink == nil {
+		return
+	}
+	letter := &DeadLetter{
+		Time:     time.Now(),
+		Event:    event,
+		ErrorMsg: errorMsg,
+	}
+	if err := sink.PutDeadLetter(ctx, letter); err != nil {
+		mux.logf("[warn] gravita: failed to put dead letter of query_id=%d request_id=%s: %v", event.QueryID, event.RequestID, err)
+	}
+}
+
+// ReplayResult is the result of replaying a DeadLetter
+type ReplayResult struct {
+	DeadLetter *DeadLetter
+	Success    bool
+	ErrorMsg   string
+	Output     string
+}
+
+// ReplayDeadLetters re-runs the events of letters through mux and reports whether they now succeed.
+// Note that the events failed again are put to mux.DeadLetterSink again, if it is set.
+func ReplayDeadLetters(ctx context.Context, mux *Mux, letters []*DeadLetter) []*ReplayResult {
+	results := make([]*ReplayResult, 0, len(letters))
+	for _, letter := range letters {
+		result := &ReplayResult{
+			DeadLetter: letter,
+		}
+		results = append(results, result)
+		output, err := mux.HandleLambdaEvent(ctx, letter.Event)
+		if err != nil {
+			result.ErrorMsg = err.Error()
+			continue
+		}
+		result.Output = output
+		var data lambdaUDFOutputData
+		if err := json.Unmarshal([]byte(output), &data); err != nil {
+			result.ErrorMsg = err.Error()
+			continue
+		}
+		result.Success = data.Success
+		result.ErrorMsg = data.ErrorMsg
+	}
+	return results
+}
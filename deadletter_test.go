@@ -0,0 +1,41 @@
+package gravita_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetter(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := gravita.NewDirectoryDeadLetterSink(dir)
+	require.NoError(t, err)
+
+	broken := true
+	mux := gravita.NewMux()
+	mux.DeadLetterSink = sink
+	mux.HandleRowFunc("*", func(_ context.Context, args []interface{}) (interface{}, error) {
+		if broken {
+			return nil, errors.New("downstream unavailable")
+		}
+		return args[0], nil
+	})
+	_, err = mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("test_udf", [][]interface{}{{"hoge"}, {"fuga"}}))
+	require.NoError(t, err)
+
+	letters, err := gravita.LoadDeadLetters(dir)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	require.Equal(t, "downstream unavailable", letters[0].ErrorMsg)
+	require.Equal(t, testLambdaUDFEvent("test_udf", [][]interface{}{{"hoge"}, {"fuga"}}), letters[0].Event)
+
+	broken = false
+	mux.DeadLetterSink = nil
+	results := gravita.ReplayDeadLetters(context.Background(), mux, letters)
+	require.Len(t, results, 1)
+	require.True(t, results[0].Success)
+	require.JSONEq(t, `{"success":true,"num_records":2,"results":["hoge","fuga"]}`, results[0].Output)
+}
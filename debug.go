@@ -0,0 +1,78 @@
+package gravita
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+// DebugCaptureEnv is the name of the environment variable that switches debug capture.
+// "1", "true", "on" or "*" enables capture of all entries configured by Entry.DebugCapture,
+// a comma separated list of external function names enables only those functions,
+// and empty, "0", "false" or "off" disables it.
+const DebugCaptureEnv = "GRAVITA_DEBUG_CAPTURE"
+
+type debugCapture struct {
+	rate       float64
+	redactions Redactions
+}
+
+// DebugCapture enables logging of sampled arguments and results of invocations that match this Entry.
+// rate is the fraction of rows to sample (0.0 - 1.0), and redactions are applied to arguments before logging.
+// Capture is performed only while it is switched on by the GRAVITA_DEBUG_CAPTURE environment variable.
+func (e *Entry) DebugCapture(rate float64, redactions ...ColumnRedaction) *Entry {
+	debug := &debugCapture{
+		rate:       rate,
+		redactions: redactions,
+	}
+	return e.update(func(s *entryState) {
+		s.debug = debug
+	})
+}
+
+func debugCaptureEnabled(exFunc string) bool {
+	value := strings.TrimSpace(os.Getenv(DebugCaptureEnv))
+	switch strings.ToLower(value) {
+	case "", "0", "false", "off":
+		return false
+	case "1", "true", "on", "*":
+		return true
+	}
+	for _, name := range strings.Split(value, ",") {
+		if strings.TrimSpace(name) == exFunc {
+			return true
+		}
+	}
+	return false
+}
+
+func (mux *Mux) captureDebug(d *debugCapture, event *LambdaUDFEvent, output *lambdaUDFOutputData) {
+	if d.rate <= 0 || !debugCaptureEnabled(event.ExternalFunction) {
+		return
+	}
+	for i, rowArgs := range event.Arguments {
+		if d.rate < 1.0 && rand.Float64() >= d.rate {
+			continue
+		}
+		args, err := json.Marshal(d.redactions.ApplyRow(rowArgs))
+		if err != nil {
+			continue
+		}
+		if !output.Success {
+			mux.logf("[debug] gravita: external_function=%s query_id=%d request_id=%s row=%d args=%s error=%q",
+				event.ExternalFunction, event.QueryID, event.RequestID, i, args, output.ErrorMsg)
+			continue
+		}
+		var result interface{}
+		if i < len(output.Results) {
+			result = output.Results[i]
+		}
+		bs, err := json.Marshal(result)
+		if err != nil {
+			continue
+		}
+		mux.logf("[debug] gravita: external_function=%s query_id=%d request_id=%s row=%d args=%s result=%s",
+			event.ExternalFunction, event.QueryID, event.RequestID, i, args, bs)
+	}
+}
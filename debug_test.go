@@ -0,0 +1,69 @@
+package gravita_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactions(t *testing.T) {
+	rs := gravita.Redactions{
+		gravita.RedactColumn(0, gravita.RedactMask),
+		gravita.RedactColumn(1, gravita.RedactDrop),
+		gravita.RedactColumn(2, gravita.RedactTruncate(3)),
+		gravita.RedactColumn(3, gravita.RedactHash),
+	}
+	actual := rs.Apply([][]interface{}{
+		{"secret", "drop", "truncated", nil, 10},
+		{12345, "drop", "ab", "x", nil},
+	})
+	require.Equal(t, []interface{}{"******", "tru", nil, 10}, actual[0])
+	require.Equal(t, gravita.RedactedValue, actual[1][0])
+	require.Equal(t, "ab", actual[1][1])
+	require.True(t, strings.HasPrefix(actual[1][2].(string), "sha256:"))
+	require.Nil(t, actual[1][3])
+
+	rs = gravita.Redactions{
+		gravita.RedactColumn(0, gravita.RedactTruncate(-1)),
+		gravita.RedactColumn(1, gravita.RedactHMAC([]byte("secret"))),
+	}
+	actual = rs.Apply([][]interface{}{{"truncated", "x"}})
+	require.Equal(t, "", actual[0][0])
+	require.True(t, strings.HasPrefix(actual[0][1].(string), "hmac-sha256:"))
+}
+
+func TestDebugCapture(t *testing.T) {
+	var buf bytes.Buffer
+	mux := gravita.NewMux()
+	mux.Logger = log.New(&buf, "", 0)
+	mux.HandleRowFunc("*", func(_ context.Context, args []interface{}) (interface{}, error) {
+		return "ok", nil
+	}).DebugCapture(1.0, gravita.RedactColumn(0, gravita.RedactMask))
+	event := testLambdaUDFEvent("test_udf", [][]interface{}{{"secret", 1}})
+
+	_, err := mux.HandleLambdaEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.Empty(t, buf.String(), "disabled by default")
+
+	for _, value := range []string{"on", "other_udf, test_udf"} {
+		buf.Reset()
+		os.Setenv(gravita.DebugCaptureEnv, value)
+		_, err = mux.HandleLambdaEvent(context.Background(), event)
+		os.Unsetenv(gravita.DebugCaptureEnv)
+		require.NoError(t, err)
+		require.Equal(t, "[debug] gravita: external_function=test_udf query_id=10 request_id=00000000-0000-0000-0000-000000000000 row=0 args=[\"******\",1] result=\"ok\"\n", buf.String())
+	}
+
+	buf.Reset()
+	os.Setenv(gravita.DebugCaptureEnv, "other_udf")
+	defer os.Unsetenv(gravita.DebugCaptureEnv)
+	_, err = mux.HandleLambdaEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.Empty(t, buf.String())
+}
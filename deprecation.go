@@ -0,0 +1,123 @@
+package gravita
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Deprecation is the deprecation notice of an Entry, see Entry.Deprecated
+type Deprecation struct {
+	// Message tells the callers what to use instead, such as "use mask_v2 instead"
+	Message string
+	// Sunset is the time after which invocations fail. Zero means no sunset
+	Sunset time.Time
+
+	mu    sync.Mutex
+	usage map[deprecationUsageKey]*DeprecationUsage
+}
+
+type deprecationUsageKey struct {
+	user    string
+	cluster string
+}
+
+// DeprecationUsage is the usage of a deprecated Entry by a user on a cluster
+type DeprecationUsage struct {
+	User      string
+	Cluster   string
+	Count     int64
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// Deprecated marks the Entry as deprecated.
+// Invocations are counted per User and Cluster, the first invocation of each pair is logged as a warning,
+// and the audit record has the warning if the Entry is audited.
+// After sunset, invocations fail with an error containing message, so that message should name the replacement.
+func (e *Entry) Deprecated(message string, sunset time.Time) *Entry {
+	d := &Deprecation{
+		Message: message,
+		Sunset:  sunset,
+		usage:   make(map[deprecationUsageKey]*DeprecationUsage),
+	}
+	return e.update(func(s *entryState) {
+		s.deprecation = d
+	})
+}
+
+// GetDeprecation returns the deprecation notice of the Entry, or nil if the Entry is not deprecated
+func (e *Entry) GetDeprecation() *Deprecation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.deprecation
+}
+
+// Usage returns the usage of the deprecated Entry ordered by User and Cluster
+func (d *Deprecation) Usage() []DeprecationUsage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	usage := make([]DeprecationUsage, 0, len(d.usage))
+	for _, u := range d.usage {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].User != usage[j].User {
+			return usage[i].User < usage[j].User
+		}
+		return usage[i].Cluster < usage[j].Cluster
+	})
+	return usage
+}
+
+// Retired reports whether the sunset has passed at now
+func (d *Deprecation) Retired(now time.Time) bool {
+	return !d.Sunset.IsZero() && !now.Before(d.Sunset)
+}
+
+// warning returns the warning of the invocation of exFunc
+func (d *Deprecation) warning(exFunc string) string {
+	msg := fmt.Sprintf("external function `%s` is deprecated", exFunc)
+	if !d.Sunset.IsZero() {
+		msg += fmt.Sprintf(" and will be retired at %s", d.Sunset.Format(time.RFC3339))
+	}
+	if d.Message != "" {
+		msg += ": " + d.Message
+	}
+	return msg
+}
+
+// use counts the invocation and reports whether it is the first invocation by the user on the cluster
+func (d *Deprecation) use(event *LambdaUDFEvent, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := deprecationUsageKey{user: event.User, cluster: event.Cluster}
+	u, ok := d.usage[key]
+	if !ok {
+		u = &DeprecationUsage{User: event.User, Cluster: event.Cluster, FirstSeen: now}
+		d.usage[key] = u
+	}
+	u.Count++
+	u.LastSeen = now
+	return !ok
+}
+
+// checkDeprecation counts the invocation of a deprecated Entry, and returns the warning, or the error after the sunset
+func (mux *Mux) checkDeprecation(d *Deprecation, event *LambdaUDFEvent) (string, error) {
+	now := time.Now()
+	first := d.use(event, now)
+	if d.Retired(now) {
+		msg := fmt.Sprintf("external function `%s` was retired at %s", event.ExternalFunction, d.Sunset.Format(time.RFC3339))
+		if d.Message != "" {
+			msg += ": " + d.Message
+		}
+		return "", errors.New(msg)
+	}
+	warning := d.warning(event.ExternalFunction)
+	if first {
+		mux.logf("[warn] gravita: %s (user=%s cluster=%s)", warning, event.User, event.Cluster)
+	}
+	return warning, nil
+}
@@ -0,0 +1,65 @@
+package gravita_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryDeprecated(t *testing.T) {
+	var buf bytes.Buffer
+	var records []*gravita.AuditRecord
+	mux := gravita.NewMux()
+	mux.Logger = log.New(&buf, "", 0)
+	mux.AuditSink = gravita.AuditSinkFunc(func(_ context.Context, record *gravita.AuditRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	echo := func(_ context.Context, args []interface{}) (interface{}, error) {
+		return args[0], nil
+	}
+	sunset := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := mux.HandleRowFunc("mask", echo).Deprecated("use mask_v2 instead", sunset).Audit(gravita.AuditArgumentsOmit)
+	retired := mux.HandleRowFunc("mask_v0", echo).Deprecated("use mask_v2 instead", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	event := testLambdaUDFEvent("mask", [][]interface{}{{1}})
+	for i := 0; i < 3; i++ {
+		actual, err := mux.HandleLambdaEvent(context.Background(), event)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"success":true,"num_records":1,"results":[1]}`, actual)
+	}
+	event.User = "etl"
+	_, err := mux.HandleLambdaEvent(context.Background(), event)
+	require.NoError(t, err)
+
+	warning := "external function `mask` is deprecated and will be retired at 2099-01-01T00:00:00Z: use mask_v2 instead"
+	require.Equal(t, []string{
+		"[warn] gravita: " + warning + " (user=test cluster=dummy)",
+		"[warn] gravita: " + warning + " (user=etl cluster=dummy)",
+	}, strings.Split(strings.TrimSpace(buf.String()), "\n"))
+	require.Len(t, records, 4)
+	require.Equal(t, warning, records[0].Warning)
+
+	usage := entry.GetDeprecation().Usage()
+	require.Len(t, usage, 2)
+	require.Equal(t, "etl", usage[0].User)
+	require.EqualValues(t, 1, usage[0].Count)
+	require.Equal(t, "test", usage[1].User)
+	require.EqualValues(t, 3, usage[1].Count)
+
+	actual, err := mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("mask_v0", [][]interface{}{{1}}))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"success":false,"error_msg":"external function `+"`mask_v0`"+` was retired at 2020-01-01T00:00:00Z: use mask_v2 instead"}`, actual)
+	require.EqualValues(t, 1, retired.GetDeprecation().Usage()[0].Count)
+
+	retired.Signature(gravita.Signature{Arguments: []gravita.SQLType{gravita.SQLInteger}, Returns: gravita.SQLInteger})
+	actual, err = mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("mask_v0", [][]interface{}{{"a"}}))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"success":false,"error_msg":"external function `+"`mask_v0`"+` was retired at 2020-01-01T00:00:00Z: use mask_v2 instead"}`, actual, "retirement takes priority over the signature")
+}
@@ -2,34 +2,119 @@ package gravita
 
 import (
 	"context"
+	"sync"
 )
 
-// Entry represents a single LambdaUDFHandler matching rule in Mux
+// Entry represents a single LambdaUDFHandler matching rule in Mux.
+// The methods of Entry are safe to call while the Mux is handling events:
+// Mux dispatches events to a copy of the Entry, which is replaced when the Entry is modified.
 type Entry struct {
-	handler  LambdaUDFHandler
-	matchers []matcher
+	entryState
+
+	mu  sync.Mutex
+	mux *Mux
+	// origin is the Entry that this copy is published from. It is nil if this is not a copy registered in a Mux
+	origin *Entry
+}
+
+// entryState is the configuration of Entry, copied when the Entry is published to a Mux
+type entryState struct {
+	name        string
+	handler     LambdaUDFHandler
+	matchers    []Matcher
+	audit       *AuditArguments
+	debug       *debugCapture
+	signature   *Signature
+	priority    int
+	overrides   map[string]string
+	deprecation *Deprecation
+	middlewares []Middleware
+
+	errs []error
+}
+
+func (s entryState) clone() entryState {
+	s.matchers = append([]Matcher(nil), s.matchers...)
+	s.middlewares = append([]Middleware(nil), s.middlewares...)
+	s.errs = append([]error(nil), s.errs...)
+	if s.overrides != nil {
+		overrides := make(map[string]string, len(s.overrides))
+		for k, v := range s.overrides {
+			overrides[k] = v
+		}
+		s.overrides = overrides
+	}
+	return s
+}
+
+// foldCase returns the copy of the published Entry whose external function matchers match case-insensitively
+func (e *Entry) foldCase() *Entry {
+	c := &Entry{
+		entryState: e.entryState,
+		origin:     e.origin,
+	}
+	c.matchers = foldMatchers(e.matchers)
+	return c
+}
+
+func foldMatchers(matchers []Matcher) []Matcher {
+	folded := make([]Matcher, len(matchers))
+	for i, m := range matchers {
+		if f, ok := m.(*externalFunctionMatcher); ok && !f.fold {
+			m = f.folded()
+		}
+		folded[i] = m
+	}
+	return folded
+}
+
+// update applies f to the Entry. If the Entry is registered to a Mux,
+// the registered copy is replaced by a new copy, so that events in flight never see a partially modified Entry.
+func (e *Entry) update(f func(s *entryState)) *Entry {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	f(&e.entryState)
+	if e.mux != nil {
+		e.mux.republish(e)
+	}
+	return e
+}
+
+// state returns a copy of the configuration of the Entry
+func (e *Entry) state() entryState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.entryState.clone()
 }
 
 // Handler registers a LambdaUDFHandler with Entry
 func (e *Entry) Handler(handler LambdaUDFHandler) *Entry {
-	e.handler = handler
-	return e
+	return e.update(func(s *entryState) {
+		s.handler = handler
+	})
 }
 
 // HandlerFunc registers a function that is the entity of LambdaUDF in Entry
 func (e *Entry) HandlerFunc(f func(context.Context, [][]interface{}) ([]interface{}, error)) *Entry {
-	e.handler = LambdaUDFHandlerFunc(f)
-	return e
+	return e.Handler(LambdaUDFHandlerFunc(f))
 }
 
 // GetHandler returns the Handler registered in the Entry
 func (e *Entry) GetHandler() LambdaUDFHandler {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	return e.handler
 }
 
-// Match determines if the given event matches this Entry
+// Match determines if the given event matches this Entry as the Mux that the Entry is registered to matches. Entry itself satisfies Matcher
 func (e *Entry) Match(event *LambdaUDFEvent) bool {
-	for _, m := range e.matchers {
+	e.mu.Lock()
+	matchers, mux := e.matchers, e.mux
+	e.mu.Unlock()
+	if mux != nil && mux.isCaseInsensitive() {
+		matchers = foldMatchers(matchers)
+	}
+	for _, m := range matchers {
 		if !m.Match(event) {
 			return false
 		}
@@ -38,6 +38,8 @@ func Metadata(ctx context.Context) *LambdaUDFEventMetadata {
 	return &LambdaUDFEventMetadata{}
 }
 
-func withMetadata(ctx context.Context, metadata *LambdaUDFEventMetadata) context.Context {
+// WithMetadata returns a copy of ctx that holds LambdaUDFEvent metadata.
+// It is useful for invoking a LambdaUDFHandler directly, e.g. in tests
+func WithMetadata(ctx context.Context, metadata *LambdaUDFEventMetadata) context.Context {
 	return context.WithValue(ctx, metadataContextKey, metadata)
 }
@@ -0,0 +1,292 @@
+package gravita
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Name sets the name of the Entry, used in introspection and logs
+func (e *Entry) Name(name string) *Entry {
+	return e.update(func(s *entryState) {
+		s.name = name
+	})
+}
+
+// GetName returns the name of the Entry
+func (e *Entry) GetName() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.name
+}
+
+// EntryDescriptor describes an Entry registered in Mux
+type EntryDescriptor struct {
+	// Path is the path of the child Mux that the Entry is registered in, such as `entry[1]/` for the child of the 2nd Entry
+	// created by Group or Mount. It is empty for the entries of the Mux itself.
+	Path string
+	// Index is the registration order of the Entry in the Mux that the Entry is registered in
+	Index     int
+	Name      string
+	Matchers  []string
+	Handler   string
+	Priority  int
+	Signature *Signature
+	// Settings are the current execution settings of the handler, see Mux.ApplyEnvSettings
+	Settings map[string]string
+	// EnvOverrides are the environment variables applied to the settings and their values
+	EnvOverrides map[string]string
+	Deprecation  *Deprecation
+}
+
+func (d EntryDescriptor) String() string {
+	label := fmt.Sprintf("%sentry[%d]", d.Path, d.Index)
+	if d.Name != "" {
+		label += fmt.Sprintf(" %q", d.Name)
+	}
+	return label
+}
+
+func describeEntry(index int, e *Entry) EntryDescriptor {
+	d := EntryDescriptor{
+		Index:       index,
+		Name:        e.name,
+		Matchers:    make([]string, 0, len(e.matchers)),
+		Priority:    e.priority,
+		Signature:   e.signature,
+		Deprecation: e.deprecation,
+	}
+	for _, m := range e.matchers {
+		d.Matchers = append(d.Matchers, describeMatcher(m))
+	}
+	if e.handler != nil {
+		d.Handler = fmt.Sprintf("%T", e.handler)
+		d.Settings = handlerSettings(e.handler)
+	}
+	if len(e.overrides) > 0 {
+		d.EnvOverrides = make(map[string]string, len(e.overrides))
+		for k, v := range e.overrides {
+			d.EnvOverrides[k] = v
+		}
+	}
+	return d
+}
+
+// Entries returns the descriptors of the registered entries in registration order.
+// The entries of a child Mux created by Group or Mount follow the Entry delegating to the child.
+func (mux *Mux) Entries() []EntryDescriptor {
+	return mux.describeEntries("", make([]EntryDescriptor, 0))
+}
+
+func (mux *Mux) describeEntries(path string, ds []EntryDescriptor) []EntryDescriptor {
+	for i, e := range mux.snapshot() {
+		d := describeEntry(i, e)
+		d.Path = path
+		ds = append(ds, d)
+		if child, _ := e.delegate(); child != nil {
+			ds = child.describeEntries(childPath(path, i), ds)
+		}
+	}
+	return ds
+}
+
+// EntryEvaluation is the result of evaluating an Entry against an event
+type EntryEvaluation struct {
+	Entry          EntryDescriptor
+	Matched        bool
+	FailedMatchers []string
+	// NilHandler is true if the Entry matched but was skipped because it has no handler
+	NilHandler bool
+}
+
+// Explanation describes how Mux routes an event
+type Explanation struct {
+	ExternalFunction string
+	// Evaluated are the entries evaluated until an Entry is chosen, in evaluation order
+	Evaluated []EntryEvaluation
+	// Chosen is the chosen Entry, or nil if no Entry matches
+	Chosen *EntryDescriptor
+	// Delegated is the explanation of the child Mux if Chosen delegates to it by Group or Mount
+	Delegated *Explanation
+}
+
+func (ex *Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "external function `%s`:", ex.ExternalFunction)
+	for _, ev := range ex.Evaluated {
+		switch {
+		case ev.NilHandler:
+			fmt.Fprintf(&b, "\n  %s: matched, but handler is nil", ev.Entry)
+		case ev.Matched:
+			fmt.Fprintf(&b, "\n  %s: matched", ev.Entry)
+		default:
+			fmt.Fprintf(&b, "\n  %s: not matched by %s", ev.Entry, strings.Join(ev.FailedMatchers, ", "))
+		}
+	}
+	if ex.Chosen == nil {
+		b.WriteString("\n  => not match")
+	} else {
+		fmt.Fprintf(&b, "\n  => %s (%s)", ex.Chosen, ex.Chosen.Handler)
+	}
+	if ex.Delegated != nil {
+		b.WriteString("\n  " + strings.ReplaceAll(ex.Delegated.String(), "\n", "\n  "))
+	}
+	return b.String()
+}
+
+// Explain returns which entries are evaluated for the event, which matchers fail, and which Entry is chosen
+func (mux *Mux) Explain(event *LambdaUDFEvent) *Explanation {
+	return mux.explain("", event)
+}
+
+func (mux *Mux) explain(path string, event *LambdaUDFEvent) *Explanation {
+	ex := &Explanation{
+		ExternalFunction: event.ExternalFunction,
+	}
+	entries := mux.snapshot()
+	for _, i := range routeOrder(entries, mux.MostSpecificMatch) {
+		e := entries[i]
+		ev := EntryEvaluation{
+			Entry: describeEntry(i, e),
+		}
+		ev.Entry.Path = path
+		for _, m := range e.matchers {
+			if !m.Match(event) {
+				ev.FailedMatchers = append(ev.FailedMatchers, describeMatcher(m))
+			}
+		}
+		ev.Matched = len(ev.FailedMatchers) == 0
+		ev.NilHandler = ev.Matched && e.handler == nil
+		ex.Evaluated = append(ex.Evaluated, ev)
+		if ev.Matched && !ev.NilHandler {
+			ex.Chosen = &ev.Entry
+			if child, _ := e.delegate(); child != nil {
+				delegated := *event
+				if h, ok := e.handler.(*mountHandler); ok {
+					delegated.ExternalFunction = h.strip(event.ExternalFunction)
+				}
+				ex.Delegated = child.explain(childPath(path, i), &delegated)
+			}
+			break
+		}
+	}
+	return ex
+}
+
+func describeMatcher(m Matcher) string {
+	if s, ok := m.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", m)
+}
+
+func describeMatchers(ms []Matcher) string {
+	strs := make([]string, 0, len(ms))
+	for _, m := range ms {
+		strs = append(strs, describeMatcher(m))
+	}
+	return strings.Join(strs, ", ")
+}
+
+func (f MatcherFunc) String() string {
+	return "MatcherFunc"
+}
+
+func (invalidMatcher) String() string {
+	return "Invalid"
+}
+
+func (m andMatcher) String() string {
+	return "And(" + describeMatchers(m) + ")"
+}
+
+func (m orMatcher) String() string {
+	return "Or(" + describeMatchers(m) + ")"
+}
+
+func (m notMatcher) String() string {
+	return "Not(" + describeMatcher(m.Matcher) + ")"
+}
+
+func (matchAllMacher) String() string {
+	return "MatchAll"
+}
+
+func (m *externalFunctionMatcher) String() string {
+	if m.fold {
+		return fmt.Sprintf("ExternalFunction(%q, case-insensitive)", m.pattern)
+	}
+	return fmt.Sprintf("ExternalFunction(%q)", m.pattern)
+}
+
+func (m *externalFunctionRegexpMatcher) String() string {
+	return fmt.Sprintf("ExternalFunctionRegexp(%q)", (*regexp.Regexp)(m).String())
+}
+
+func (m userMatcher) String() string {
+	return fmt.Sprintf("User(%q)", string(m))
+}
+
+func (m *userGlobMatcher) String() string {
+	return fmt.Sprintf("User(%q)", m.pattern)
+}
+
+func (m *userRegexpMatcher) String() string {
+	return fmt.Sprintf("UserRegexp(%q)", (*regexp.Regexp)(m).String())
+}
+
+func (m clusterMatcher) String() string {
+	return fmt.Sprintf("Cluster(%q)", string(m))
+}
+
+func (m *clusterGlobMatcher) String() string {
+	return fmt.Sprintf("Cluster(%q)", m.pattern)
+}
+
+func (m *clusterRegexpMatcher) String() string {
+	return fmt.Sprintf("ClusterRegexp(%q)", (*regexp.Regexp)(m).String())
+}
+
+func (m databaseMatcher) String() string {
+	return fmt.Sprintf("Database(%q)", string(m))
+}
+
+func (m *databaseGlobMatcher) String() string {
+	return fmt.Sprintf("Database(%q)", m.pattern)
+}
+
+func (m *databaseRegexpMatcher) String() string {
+	return fmt.Sprintf("DatabaseRegexp(%q)", (*regexp.Regexp)(m).String())
+}
+
+func (m requestIDMatcher) String() string {
+	return fmt.Sprintf("RequestID(%q)", string(m))
+}
+
+func (m *requestIDGlobMatcher) String() string {
+	return fmt.Sprintf("RequestID(%q)", m.pattern)
+}
+
+func (m queryIDRangeMatcher) String() string {
+	return fmt.Sprintf("QueryIDRange(%d, %d)", m.min, m.max)
+}
+
+func (m numRecordsRangeMatcher) String() string {
+	return fmt.Sprintf("NumRecordsRange(%d, %d)", m.min, m.max)
+}
+
+func (m numArgumentsMatcher) String() string {
+	return fmt.Sprintf("NumArguments(%d)", int(m))
+}
+
+func (m argumentTypesMatcher) String() string {
+	types := make([]string, 0, len(m))
+	for _, t := range m {
+		types = append(types, t.String())
+	}
+	return "ArgumentTypes(" + strings.Join(types, ", ") + ")"
+}
+
+func (m argumentValueMatcher) String() string {
+	return fmt.Sprintf("ArgumentValue(%d)", m.column)
+}
@@ -0,0 +1,67 @@
+package gravita_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuxEntries(t *testing.T) {
+	mux := gravita.NewMux()
+	mux.HandleRowFunc("mask_*", func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return nil, nil
+	}).Name("mask").Cluster("prod").NumArguments(1).Priority(2)
+	mux.NewEntry().Matcher(gravita.Or(gravita.UserMatcher("etl"), gravita.Not(gravita.DatabaseMatcher("dev"))))
+
+	require.Equal(t, []gravita.EntryDescriptor{
+		{
+			Index:    0,
+			Name:     "mask",
+			Matchers: []string{`ExternalFunction("mask_*")`, `Cluster("prod")`, `NumArguments(1)`},
+			Handler:  "gravita.ParallelRowProcessHandler",
+			Priority: 2,
+			Settings: map[string]string{gravita.SettingMaxConcurrency: "0"},
+		},
+		{
+			Index:    1,
+			Matchers: []string{`Or(User("etl"), Not(Database("dev")))`},
+		},
+	}, mux.Entries())
+}
+
+func TestMuxExplain(t *testing.T) {
+	var buf bytes.Buffer
+	mux := gravita.NewMux()
+	mux.Logger = log.New(&buf, "", 0)
+	mux.TraceRouting = true
+	mux.NewEntry().ExternalFunction("test_*").Name("no handler")
+	mux.HandleFunc("test_*", func(_ context.Context, _ [][]interface{}) ([]interface{}, error) {
+		return nil, nil
+	}).Name("prod only").Cluster("prod").User("t*")
+	mux.HandleRowFunc("*", func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return nil, nil
+	}).Name("fallback")
+	mux.HandleRowFunc("other", func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return nil, nil
+	})
+
+	ex := mux.Explain(testLambdaUDFEvent("test_udf", nil))
+	require.Len(t, ex.Evaluated, 3)
+	require.True(t, ex.Evaluated[0].NilHandler)
+	require.Equal(t, []string{`Cluster("prod")`}, ex.Evaluated[1].FailedMatchers)
+	require.Equal(t, "fallback", ex.Chosen.Name)
+	expected := "external function `test_udf`:\n" +
+		"  entry[0] \"no handler\": matched, but handler is nil\n" +
+		"  entry[1] \"prod only\": not matched by Cluster(\"prod\")\n" +
+		"  entry[2] \"fallback\": matched\n" +
+		"  => entry[2] \"fallback\" (gravita.ParallelRowProcessHandler)"
+	require.Equal(t, expected, ex.String())
+
+	_, err := mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("test_udf", nil))
+	require.NoError(t, err)
+	require.Equal(t, "[debug] gravita: "+expected+"\n", buf.String())
+}
@@ -0,0 +1,100 @@
+package gravita
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// glob is a compiled wildcard pattern that matches the whole string.
+// `*` matches any sequence of characters, `?` matches any single character,
+// `[abc]`, `[a-z]` and `[!abc]` (or `[^abc]`) match a character class, and `\c` matches c literally.
+type glob struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// hasGlobMeta reports whether pattern contains any glob meta characters
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+func compileGlob(pattern string) (*glob, error) {
+	var b strings.Builder
+	b.WriteString(`^(?s:`)
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		case '\\':
+			i++
+			if i >= len(runes) {
+				return nil, fmt.Errorf("glob `%s`: %w", pattern, errors.New("trailing backslash"))
+			}
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '[':
+			class, n, err := globClass(runes[i:])
+			if err != nil {
+				return nil, fmt.Errorf("glob `%s`: %w", pattern, err)
+			}
+			b.WriteString(class)
+			i += n - 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString(`)$`)
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("glob `%s`: %w", pattern, err)
+	}
+	return &glob{pattern: pattern, re: re}, nil
+}
+
+// globClass translates a character class at the head of runes, and returns the number of consumed runes
+func globClass(runes []rune) (string, int, error) {
+	var b strings.Builder
+	b.WriteRune('[')
+	i := 1
+	if i < len(runes) && (runes[i] == '!' || runes[i] == '^') {
+		b.WriteRune('^')
+		i++
+	}
+	for first := true; i < len(runes); first = false {
+		c := runes[i]
+		if c == ']' && !first {
+			b.WriteRune(']')
+			return b.String(), i + 1, nil
+		}
+		escaped := false
+		if c == '\\' {
+			i++
+			if i >= len(runes) {
+				break
+			}
+			c = runes[i]
+			escaped = true
+		}
+		if c == '-' && !escaped && !first && i+1 < len(runes) && runes[i+1] != ']' {
+			b.WriteRune('-')
+			i++
+			continue
+		}
+		if c == '-' {
+			b.WriteString(`\-`)
+		} else {
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+		i++
+	}
+	return "", 0, errors.New("unterminated character class")
+}
+
+// MatchString reports whether s matches the whole glob pattern
+func (g *glob) MatchString(s string) bool {
+	return g.re.MatchString(s)
+}
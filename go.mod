@@ -5,4 +5,5 @@ go 1.16
 require (
 	github.com/stretchr/testify v1.8.1
 	golang.org/x/sync v0.1.0
+	gopkg.in/yaml.v3 v3.0.1
 )
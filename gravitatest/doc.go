@@ -0,0 +1,17 @@
+/*
+Package gravitatest provides utilities for testing gravita LambdaUDF handlers.
+
+	func TestConcat(t *testing.T) {
+		mux := gravita.NewMux()
+		mux.HandleRowFunc("concat", func(_ context.Context, args []interface{}) (interface{}, error) {
+			return fmt.Sprint(args...), nil
+		})
+		event := gravitatest.NewEvent("concat").
+			Row("hoge", 1).
+			Row("fuga", 2).
+			Build()
+		out := gravitatest.Invoke(t, mux, event)
+		gravitatest.AssertResults(t, out, "hoge1", "fuga2")
+	}
+*/
+package gravitatest
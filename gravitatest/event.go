@@ -0,0 +1,96 @@
+package gravitatest
+
+import "github.com/mashiike/gravita"
+
+// Default metadata values of events built by EventBuilder
+const (
+	DefaultRequestID = "00000000-0000-0000-0000-000000000000"
+	DefaultCluster   = "arn:aws:redshift:us-east-1:123456789012:cluster:dummy"
+	DefaultUser      = "test"
+	DefaultDatabase  = "dev"
+	DefaultQueryID   = 1
+)
+
+// EventBuilder builds a LambdaUDFEvent
+type EventBuilder struct {
+	event      gravita.LambdaUDFEvent
+	numRecords *int
+}
+
+// NewEvent returns an EventBuilder of the external function with default metadata
+func NewEvent(exFunc string) *EventBuilder {
+	return &EventBuilder{
+		event: gravita.LambdaUDFEvent{
+			LambdaUDFEventMetadata: gravita.LambdaUDFEventMetadata{
+				RequestID:        DefaultRequestID,
+				Cluster:          DefaultCluster,
+				User:             DefaultUser,
+				Database:         DefaultDatabase,
+				ExternalFunction: exFunc,
+				QueryID:          DefaultQueryID,
+			},
+			Arguments: [][]interface{}{},
+		},
+	}
+}
+
+// RequestID sets the request_id of the event
+func (b *EventBuilder) RequestID(requestID string) *EventBuilder {
+	b.event.RequestID = requestID
+	return b
+}
+
+// Cluster sets the cluster of the event
+func (b *EventBuilder) Cluster(cluster string) *EventBuilder {
+	b.event.Cluster = cluster
+	return b
+}
+
+// User sets the user of the event
+func (b *EventBuilder) User(user string) *EventBuilder {
+	b.event.User = user
+	return b
+}
+
+// Database sets the database of the event
+func (b *EventBuilder) Database(database string) *EventBuilder {
+	b.event.Database = database
+	return b
+}
+
+// QueryID sets the query_id of the event
+func (b *EventBuilder) QueryID(queryID int) *EventBuilder {
+	b.event.QueryID = queryID
+	return b
+}
+
+// NumRecords overrides the num_records of the event, which is the number of rows by default
+func (b *EventBuilder) NumRecords(n int) *EventBuilder {
+	b.numRecords = &n
+	return b
+}
+
+// Row appends a row of arguments
+func (b *EventBuilder) Row(args ...interface{}) *EventBuilder {
+	b.event.Arguments = append(b.event.Arguments, args)
+	return b
+}
+
+// Rows appends rows of arguments
+func (b *EventBuilder) Rows(rows [][]interface{}) *EventBuilder {
+	b.event.Arguments = append(b.event.Arguments, rows...)
+	return b
+}
+
+// Build returns the LambdaUDFEvent
+func (b *EventBuilder) Build() *gravita.LambdaUDFEvent {
+	event := b.event
+	event.Arguments = make([][]interface{}, len(b.event.Arguments))
+	copy(event.Arguments, b.event.Arguments)
+	if b.numRecords != nil {
+		event.NumRecords = *b.numRecords
+	} else {
+		event.NumRecords = len(event.Arguments)
+	}
+	return &event
+}
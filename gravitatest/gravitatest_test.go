@@ -0,0 +1,72 @@
+package gravitatest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/mashiike/gravita/gravitatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBuilder(t *testing.T) {
+	event := gravitatest.NewEvent("concat").
+		User("etl").
+		QueryID(100).
+		Row("hoge", 1).
+		Rows([][]interface{}{{"fuga", 2}}).
+		Build()
+	require.Equal(t, &gravita.LambdaUDFEvent{
+		LambdaUDFEventMetadata: gravita.LambdaUDFEventMetadata{
+			RequestID:        gravitatest.DefaultRequestID,
+			Cluster:          gravitatest.DefaultCluster,
+			User:             "etl",
+			Database:         gravitatest.DefaultDatabase,
+			ExternalFunction: "concat",
+			QueryID:          100,
+			NumRecords:       2,
+		},
+		Arguments: [][]interface{}{{"hoge", 1}, {"fuga", 2}},
+	}, event)
+}
+
+func TestInvoke(t *testing.T) {
+	mux := gravita.NewMux()
+	entry := mux.HandleRowFunc("concat", func(ctx context.Context, args []interface{}) (interface{}, error) {
+		return gravita.Metadata(ctx).User + ":" + fmt.Sprint(args...), nil
+	})
+	mux.HandleFunc("fail", func(_ context.Context, _ [][]interface{}) ([]interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	event := gravitatest.NewEvent("concat").Row("hoge", 1).Row("fuga", 2).Build()
+
+	out := gravitatest.Invoke(t, mux, event)
+	gravitatest.AssertResults(t, out, "test:hoge1", "test:fuga2")
+	out = gravitatest.InvokeEntry(t, entry, event)
+	gravitatest.AssertResults(t, out, "test:hoge1", "test:fuga2")
+
+	out = gravitatest.Invoke(t, mux, gravitatest.NewEvent("fail").Row(1).Build())
+	gravitatest.AssertError(t, out, "failed")
+
+	out = gravitatest.InvokeHandler(t, gravita.LambdaUDFHandlerFunc(func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+		return []interface{}{len(args)}, nil
+	}), gravitatest.NewEvent("count").Row(1).Row(2).Build())
+	gravitatest.AssertResults(t, out, 2, nil)
+
+	ctx := gravitatest.Context(context.Background(), event)
+	require.Equal(t, "concat", gravita.Metadata(ctx).ExternalFunction)
+}
+
+func TestInvokeEntrySignature(t *testing.T) {
+	mux := gravita.NewMux()
+	entry := mux.HandleRowFunc("add_one", func(_ context.Context, args []interface{}) (interface{}, error) {
+		return args[0].(int) + 1, nil
+	}).Signature(gravita.Signature{Arguments: []gravita.SQLType{gravita.SQLInteger}, Returns: gravita.SQLInteger})
+
+	out := gravitatest.InvokeEntry(t, entry, gravitatest.NewEvent("add_one").Row(1).Build())
+	gravitatest.AssertResults(t, out, 2)
+	out = gravitatest.InvokeEntry(t, entry, gravitatest.NewEvent("add_one").Row("one").Build())
+	gravitatest.AssertError(t, out, "external function `add_one`: row 1: argument $1 expected INTEGER (number), got string")
+}
@@ -0,0 +1,52 @@
+package gravitatest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mashiike/gravita"
+)
+
+// Context returns a context that holds the metadata of the event, as passed to handlers by Mux
+func Context(ctx context.Context, event *gravita.LambdaUDFEvent) context.Context {
+	metadata := event.LambdaUDFEventMetadata
+	return gravita.WithMetadata(ctx, &metadata)
+}
+
+// Invoke invokes mux with the event and returns the decoded output
+func Invoke(t testing.TB, mux *gravita.Mux, event *gravita.LambdaUDFEvent) *Output {
+	t.Helper()
+	jsonStr, err := mux.HandleLambdaEvent(context.Background(), event)
+	if err != nil {
+		t.Fatalf("HandleLambdaEvent failed: %v", err)
+	}
+	out, err := DecodeOutput(jsonStr)
+	if err != nil {
+		t.Fatalf("invalid output %q: %v", jsonStr, err)
+	}
+	return out
+}
+
+// InvokeHandler invokes the handler with the event in the same way as Mux does
+func InvokeHandler(t testing.TB, handler gravita.LambdaUDFHandler, event *gravita.LambdaUDFEvent) *Output {
+	t.Helper()
+	mux := gravita.NewMux()
+	mux.NewEntry().Handler(handler)
+	return Invoke(t, mux, event)
+}
+
+// InvokeEntry invokes the entry with the event through a Mux that has only a copy of the entry,
+// so that the Signature, Deprecated and Audit settings of the entry are applied as in production.
+// Settings of the Mux where the entry is registered, such as AuditSink, are not used.
+func InvokeEntry(t testing.TB, entry *gravita.Entry, event *gravita.LambdaUDFEvent) *Output {
+	t.Helper()
+	if !entry.Match(event) {
+		t.Fatalf("entry does not match external function `%s`", event.ExternalFunction)
+	}
+	if entry.GetHandler() == nil {
+		t.Fatalf("entry has no handler")
+	}
+	mux := gravita.NewMux()
+	mux.AddEntry(entry.Clone())
+	return Invoke(t, mux, event)
+}
@@ -0,0 +1,93 @@
+package gravitatest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Output is the decoded output of Mux.HandleLambdaEvent
+type Output struct {
+	Success    bool          `json:"success"`
+	ErrorMsg   string        `json:"error_msg,omitempty"`
+	NumRecords int           `json:"num_records,omitempty"`
+	Results    []interface{} `json:"results,omitempty"`
+}
+
+// DecodeOutput decodes the output of Mux.HandleLambdaEvent
+func DecodeOutput(jsonStr string) (*Output, error) {
+	var out Output
+	if err := json.Unmarshal([]byte(jsonStr), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AssertSuccess asserts that the output is success
+func AssertSuccess(t testing.TB, out *Output) bool {
+	t.Helper()
+	if !out.Success {
+		t.Errorf("expected success, but failed: %s", out.ErrorMsg)
+		return false
+	}
+	return true
+}
+
+// AssertError asserts that the output is failed with the error message
+func AssertError(t testing.TB, out *Output, errorMsg string) bool {
+	t.Helper()
+	if out.Success {
+		t.Errorf("expected error %q, but succeeded", errorMsg)
+		return false
+	}
+	if out.ErrorMsg != errorMsg {
+		t.Errorf("error_msg mismatch\nexpected: %q\nactual  : %q", errorMsg, out.ErrorMsg)
+		return false
+	}
+	return true
+}
+
+// AssertResults asserts that the output is success with the results.
+// Results are compared as JSON values, so that 1 and 1.0 are equal.
+func AssertResults(t testing.TB, out *Output, expected ...interface{}) bool {
+	t.Helper()
+	if !AssertSuccess(t, out) {
+		return false
+	}
+	if expected == nil {
+		expected = []interface{}{}
+	}
+	expectedJSON, err := normalizeJSON(expected)
+	if err != nil {
+		t.Errorf("expected results can not encode as JSON: %v", err)
+		return false
+	}
+	actual := out.Results
+	if actual == nil {
+		actual = []interface{}{}
+	}
+	actualJSON, err := normalizeJSON(actual)
+	if err != nil {
+		t.Errorf("results can not encode as JSON: %v", err)
+		return false
+	}
+	if !reflect.DeepEqual(expectedJSON, actualJSON) {
+		e, _ := json.Marshal(expectedJSON)
+		a, _ := json.Marshal(actualJSON)
+		t.Errorf("results mismatch\nexpected: %s\nactual  : %s", e, a)
+		return false
+	}
+	return true
+}
+
+func normalizeJSON(v interface{}) (interface{}, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var ret interface{}
+	if err := json.Unmarshal(bs, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
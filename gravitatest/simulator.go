@@ -0,0 +1,234 @@
+package gravitatest
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mashiike/gravita"
+)
+
+// Default settings of Simulator
+const (
+	DefaultBatchSize       = 1000
+	DefaultMaxPayloadBytes = 6 * 1024 * 1024
+	DefaultConcurrency     = 4
+)
+
+// Simulator simulates how Redshift splits rows of a query into LambdaUDFEvents and invokes Lambda
+type Simulator struct {
+	// Metadata is the template of event metadata. QueryID is shared by all invocations
+	Metadata gravita.LambdaUDFEventMetadata
+	// BatchSize is the maximum number of rows per invocation
+	BatchSize int
+	// MaxPayloadBytes is the maximum size of the JSON encoded event
+	MaxPayloadBytes int
+	// Concurrency is the number of concurrent invocations
+	Concurrency int
+	// MaxRetries is the number of retries when the invocation returns an error
+	MaxRetries int
+}
+
+// NewSimulator returns a Simulator of the external function with default settings
+func NewSimulator(exFunc string) *Simulator {
+	return &Simulator{
+		Metadata: gravita.LambdaUDFEventMetadata{
+			Cluster:          DefaultCluster,
+			User:             DefaultUser,
+			Database:         DefaultDatabase,
+			ExternalFunction: exFunc,
+			QueryID:          DefaultQueryID,
+		},
+		BatchSize:       DefaultBatchSize,
+		MaxPayloadBytes: DefaultMaxPayloadBytes,
+		Concurrency:     DefaultConcurrency,
+	}
+}
+
+// Invocation represents a single simulated Lambda invocation
+type Invocation struct {
+	RequestID  string
+	Offset     int
+	NumRecords int
+	Attempts   int
+	Duration   time.Duration
+	Success    bool
+	ErrorMsg   string
+}
+
+// SimulationReport is the result of Simulator.Run
+type SimulationReport struct {
+	// Results are the reassembled results per row. rows of failed invocations are nil
+	Results     []interface{}
+	Invocations []*Invocation
+	Failures    []*Invocation
+	Duration    time.Duration
+}
+
+// OK reports whether all invocations succeeded
+func (r *SimulationReport) OK() bool {
+	return len(r.Failures) == 0
+}
+
+// Run splits rows into events, invokes mux and reassembles results
+func (s *Simulator) Run(ctx context.Context, mux *gravita.Mux, rows [][]interface{}) (*SimulationReport, error) {
+	batches, err := s.split(rows)
+	if err != nil {
+		return nil, err
+	}
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	report := &SimulationReport{
+		Results:     make([]interface{}, len(rows)),
+		Invocations: make([]*Invocation, len(batches)),
+	}
+	startAt := time.Now()
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, b := range batches {
+		event := &gravita.LambdaUDFEvent{
+			LambdaUDFEventMetadata: s.Metadata,
+			Arguments:              rows[b.offset : b.offset+b.n],
+		}
+		event.RequestID = fmt.Sprintf("%08x-0000-4000-8000-%012x", s.Metadata.QueryID, i)
+		event.NumRecords = b.n
+		inv := &Invocation{
+			RequestID:  event.RequestID,
+			Offset:     b.offset,
+			NumRecords: b.n,
+		}
+		report.Invocations[i] = inv
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			s.invoke(ctx, mux, event, inv, report.Results)
+		}()
+	}
+	wg.Wait()
+	report.Duration = time.Since(startAt)
+	for _, inv := range report.Invocations {
+		if !inv.Success {
+			report.Failures = append(report.Failures, inv)
+		}
+	}
+	return report, nil
+}
+
+func (s *Simulator) invoke(ctx context.Context, mux *gravita.Mux, event *gravita.LambdaUDFEvent, inv *Invocation, results []interface{}) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		inv.ErrorMsg = err.Error()
+		return
+	}
+	startAt := time.Now()
+	defer func() {
+		inv.Duration = time.Since(startAt)
+	}()
+	for inv.Attempts <= s.MaxRetries {
+		inv.Attempts++
+		// decode from JSON as Lambda runtime does, so that handlers see the same types as in production
+		var decoded gravita.LambdaUDFEvent
+		if err := json.Unmarshal(payload, &decoded); err != nil {
+			inv.ErrorMsg = err.Error()
+			return
+		}
+		jsonStr, err := mux.HandleLambdaEvent(ctx, &decoded)
+		if err != nil {
+			inv.ErrorMsg = err.Error()
+			continue
+		}
+		out, err := DecodeOutput(jsonStr)
+		if err != nil {
+			inv.ErrorMsg = err.Error()
+			return
+		}
+		inv.Success = out.Success
+		inv.ErrorMsg = out.ErrorMsg
+		if !out.Success {
+			return
+		}
+		if len(out.Results) != inv.NumRecords {
+			inv.Success = false
+			inv.ErrorMsg = fmt.Sprintf("num results %d not equal num records %d", len(out.Results), inv.NumRecords)
+			return
+		}
+		copy(results[inv.Offset:inv.Offset+inv.NumRecords], out.Results)
+		return
+	}
+}
+
+type batch struct {
+	offset int
+	n      int
+}
+
+// eventOverheadBytes is the estimated size of the event excepting arguments
+const eventOverheadBytes = 512
+
+func (s *Simulator) split(rows [][]interface{}) ([]batch, error) {
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	maxPayloadBytes := s.MaxPayloadBytes
+	if maxPayloadBytes <= 0 {
+		maxPayloadBytes = DefaultMaxPayloadBytes
+	}
+	batches := make([]batch, 0)
+	current := batch{}
+	size := eventOverheadBytes
+	for i, row := range rows {
+		bs, err := json.Marshal(row)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		rowBytes := len(bs) + 1
+		if eventOverheadBytes+rowBytes > maxPayloadBytes {
+			return nil, fmt.Errorf("row %d: payload size %d bytes exceeds max payload bytes %d", i, rowBytes, maxPayloadBytes)
+		}
+		if current.n > 0 && (current.n >= batchSize || size+rowBytes > maxPayloadBytes) {
+			batches = append(batches, current)
+			current = batch{offset: i}
+			size = eventOverheadBytes
+		}
+		current.n++
+		size += rowBytes
+	}
+	if current.n > 0 {
+		batches = append(batches, current)
+	}
+	return batches, nil
+}
+
+// ReadCSV reads rows of arguments from CSV. Each field is a string, and an empty field is NULL
+func ReadCSV(r io.Reader) ([][]interface{}, error) {
+	reader := csv.NewReader(r)
+	rows := make([][]interface{}, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make([]interface{}, len(record))
+		for i, field := range record {
+			if field != "" {
+				row[i] = field
+			}
+		}
+		rows = append(rows, row)
+	}
+}
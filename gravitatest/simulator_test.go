@@ -0,0 +1,76 @@
+package gravitatest_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/mashiike/gravita/gravitatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulator(t *testing.T) {
+	rows, err := gravitatest.ReadCSV(strings.NewReader("hoge,1\nfuga,\npiyo,3\ntora,4\nfail,5\n"))
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"fuga", nil}, rows[1])
+
+	var calls, maxRecords int32
+	queryIDs := make(chan int, 10)
+	mux := gravita.NewMux()
+	mux.HandleFunc("concat", func(ctx context.Context, args [][]interface{}) ([]interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		if n := int32(len(args)); n > atomic.LoadInt32(&maxRecords) {
+			atomic.StoreInt32(&maxRecords, n)
+		}
+		queryIDs <- gravita.Metadata(ctx).QueryID
+		ret := make([]interface{}, 0, len(args))
+		for _, row := range args {
+			if row[0] == "fail" {
+				return nil, errors.New("failed")
+			}
+			ret = append(ret, row[0])
+		}
+		return ret, nil
+	})
+
+	sim := gravitatest.NewSimulator("concat")
+	sim.BatchSize = 2
+	sim.Metadata.QueryID = 42
+	report, err := sim.Run(context.Background(), mux, rows)
+	require.NoError(t, err)
+	close(queryIDs)
+	for id := range queryIDs {
+		require.Equal(t, 42, id)
+	}
+	require.EqualValues(t, 3, calls)
+	require.EqualValues(t, 2, maxRecords)
+	require.False(t, report.OK())
+	require.Len(t, report.Invocations, 3)
+	require.Len(t, report.Failures, 1)
+	require.Equal(t, 4, report.Failures[0].Offset)
+	require.Equal(t, "failed", report.Failures[0].ErrorMsg)
+	require.Equal(t, []interface{}{"hoge", "fuga", "piyo", "tora", nil}, report.Results)
+}
+
+func TestSimulatorPayloadLimit(t *testing.T) {
+	mux := gravita.NewMux()
+	mux.HandleRowFunc("*", func(_ context.Context, args []interface{}) (interface{}, error) {
+		return len(args[0].(string)), nil
+	})
+	rows := make([][]interface{}, 10)
+	for i := range rows {
+		rows[i] = []interface{}{strings.Repeat("x", 100)}
+	}
+	sim := gravitatest.NewSimulator("length")
+	sim.MaxPayloadBytes = 512 + 3*105
+	report, err := sim.Run(context.Background(), mux, rows)
+	require.NoError(t, err)
+	require.True(t, report.OK())
+	require.Len(t, report.Invocations, 4)
+	for _, result := range report.Results {
+		require.EqualValues(t, 100, result)
+	}
+}
@@ -0,0 +1,171 @@
+package gravita
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Middleware wraps a LambdaUDFHandler to add behavior before and after it
+type Middleware func(LambdaUDFHandler) LambdaUDFHandler
+
+// Use appends middlewares applied to the handler chosen by the Mux, including NotMatchHandler.
+// Use(a, b) calls a, b and then the handler.
+func (mux *Mux) Use(middlewares ...Middleware) *Mux {
+	mux.middlewares = append(mux.middlewares, middlewares...)
+	return mux
+}
+
+func (mux *Mux) wrap(handler LambdaUDFHandler) LambdaUDFHandler {
+	for i := len(mux.middlewares) - 1; i >= 0; i-- {
+		handler = mux.middlewares[i](handler)
+	}
+	return handler
+}
+
+// Use appends middlewares applied to the handler of the Entry, inside the middlewares of the Mux.
+// The middlewares are applied on dispatch, so that the handler of the Entry stays reachable by ApplyEnvSettings.
+func (e *Entry) Use(middlewares ...Middleware) *Entry {
+	return e.update(func(s *entryState) {
+		s.middlewares = append(s.middlewares, middlewares...)
+	})
+}
+
+func (e *Entry) wrap(handler LambdaUDFHandler) LambdaUDFHandler {
+	for i := len(e.middlewares) - 1; i >= 0; i-- {
+		handler = e.middlewares[i](handler)
+	}
+	return handler
+}
+
+// Group registers an Entry that delegates the events matched by matchers to a new child Mux, and returns the child.
+// The child has its own entries, middlewares and NotMatchHandler; if no Entry of the child matches, the event is not passed back to the parent.
+// AuditSink, AuditHashKey, Logger, DeadLetterSink, Switches and CaseInsensitive of the parent are used if the child does not set them.
+func (mux *Mux) Group(matchers ...Matcher) *Mux {
+	child := NewMux()
+	child.parent = mux
+	mux.AddEntry(NewEntry().Matcher(matchers...).Handler(child))
+	return child
+}
+
+// Mount registers an Entry that delegates the events whose external function name starts with prefix to child.
+// The child routes the events by the external function name without prefix, and inherits the settings of mux as a child created by Group.
+// A Mux can be mounted to only one parent.
+func (mux *Mux) Mount(prefix string, child *Mux) *Entry {
+	child.parent = mux
+	return mux.AddEntry(NewEntry().
+		ExternalFunction(escapeGlob(prefix) + "*").
+		Handler(&mountHandler{prefix: prefix, child: child, parent: mux}))
+}
+
+// mountHandler strips the prefix from the external function name and delegates to the child Mux
+type mountHandler struct {
+	prefix string
+	child  *Mux
+	parent *Mux
+}
+
+func (h *mountHandler) ExecuteUDF(ctx context.Context, args [][]interface{}) ([]interface{}, error) {
+	metadata := Metadata(ctx)
+	metadata.ExternalFunction = h.strip(metadata.ExternalFunction)
+	return h.child.ExecuteUDF(WithMetadata(ctx, metadata), args)
+}
+
+// strip returns the external function name without the prefix
+func (h *mountHandler) strip(exFunc string) string {
+	name := ParseExternalFunction(exFunc)
+	switch {
+	case h.hasPrefix(exFunc):
+		return exFunc[len(h.prefix):]
+	case name.Schema != "" && h.hasPrefix(name.Function):
+		name.Function = name.Function[len(h.prefix):]
+		return name.String()
+	}
+	return exFunc
+}
+
+// hasPrefix reports whether s starts with the prefix, case-insensitively if the parent matches case-insensitively as the matcher of the mount does
+func (h *mountHandler) hasPrefix(s string) bool {
+	if h.parent.isCaseInsensitive() {
+		return len(s) >= len(h.prefix) && strings.EqualFold(s[:len(h.prefix)], h.prefix)
+	}
+	return strings.HasPrefix(s, h.prefix)
+}
+
+// delegate returns the child Mux that the Entry delegates events to, by Group, Mount or a *Mux handler, and the prefix of Mount.
+// It returns nil if the Entry does not delegate.
+func (e *Entry) delegate() (*Mux, string) {
+	switch h := e.handler.(type) {
+	case *Mux:
+		return h, ""
+	case *mountHandler:
+		return h.child, h.prefix
+	}
+	return nil, ""
+}
+
+// childPath returns the path of the child Mux that the i-th Entry delegates to, such as `entry[1]/`
+func childPath(path string, i int) string {
+	return fmt.Sprintf("%sentry[%d]/", path, i)
+}
+
+// auditSink returns the AuditSink of the Mux or the nearest parent, or nil
+func (mux *Mux) auditSink() AuditSink {
+	for m := mux; m != nil; m = m.parent {
+		if m.AuditSink != nil {
+			return m.AuditSink
+		}
+	}
+	return nil
+}
+
+// auditHashKey returns the AuditHashKey of the Mux or the nearest parent, or nil
+func (mux *Mux) auditHashKey() []byte {
+	for m := mux; m != nil; m = m.parent {
+		if len(m.AuditHashKey) > 0 {
+			return m.AuditHashKey
+		}
+	}
+	return nil
+}
+
+// logger returns the Logger of the Mux or the nearest parent, or nil
+func (mux *Mux) logger() Logger {
+	for m := mux; m != nil; m = m.parent {
+		if m.Logger != nil {
+			return m.Logger
+		}
+	}
+	return nil
+}
+
+// deadLetterSink returns the DeadLetterSink of the Mux or the nearest parent, or nil
+func (mux *Mux) deadLetterSink() DeadLetterSink {
+	for m := mux; m != nil; m = m.parent {
+		if m.DeadLetterSink != nil {
+			return m.DeadLetterSink
+		}
+	}
+	return nil
+}
+
+// switches returns the SwitchBoard of the Mux or the nearest parent, or nil
+func (mux *Mux) switches() *SwitchBoard {
+	for m := mux; m != nil; m = m.parent {
+		if m.Switches != nil {
+			return m.Switches
+		}
+	}
+	return nil
+}
+
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[]\`, c) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
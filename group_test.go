@@ -0,0 +1,230 @@
+package gravita_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuxMount(t *testing.T) {
+	child := gravita.NewMux()
+	child.HandleRowFunc("mask", func(ctx context.Context, _ []interface{}) (interface{}, error) {
+		return "team_a:" + gravita.Metadata(ctx).ExternalFunction, nil
+	})
+	child.Use(func(next gravita.LambdaUDFHandler) gravita.LambdaUDFHandler {
+		return gravita.LambdaUDFHandlerFunc(func(ctx context.Context, args [][]interface{}) ([]interface{}, error) {
+			results, err := next.ExecuteUDF(ctx, args)
+			if err != nil {
+				return nil, err
+			}
+			for i := range results {
+				results[i] = results[i].(string) + "!"
+			}
+			return results, nil
+		})
+	})
+	mux := gravita.NewMux()
+	mux.Mount("team_a_", child)
+	mux.HandleRowFunc("*", func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return "parent", nil
+	})
+
+	cases := []struct {
+		exFunc   string
+		expected string
+	}{
+		{
+			exFunc:   "team_a_mask",
+			expected: `{"success":true,"num_records":2,"results":["team_a:mask!","team_a:mask!"]}`,
+		},
+		{
+			exFunc:   "analytics.team_a_mask",
+			expected: `{"success":true,"num_records":2,"results":["team_a:analytics.mask!","team_a:analytics.mask!"]}`,
+		},
+		{
+			exFunc:   "team_a_unknown",
+			expected: `{"success":false,"error_msg":"external function ` + "`unknown`" + ` not match"}`,
+		},
+		{
+			exFunc:   "team_b_mask",
+			expected: `{"success":true,"num_records":2,"results":["parent","parent"]}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.exFunc, func(t *testing.T) {
+			event := testLambdaUDFEvent(c.exFunc, [][]interface{}{{1}, {2}})
+			actual, err := mux.HandleLambdaEvent(context.Background(), event)
+			require.NoError(t, err)
+			require.JSONEq(t, c.expected, actual)
+		})
+	}
+}
+
+func TestMuxMountCaseInsensitive(t *testing.T) {
+	child := gravita.NewMux()
+	child.HandleRowFunc("mask", func(ctx context.Context, _ []interface{}) (interface{}, error) {
+		return "team_a:" + gravita.Metadata(ctx).ExternalFunction, nil
+	})
+	mux := gravita.NewMux().CaseInsensitive(true)
+	mux.Mount("Team_A_", child)
+
+	cases := []struct {
+		exFunc   string
+		expected string
+	}{
+		{
+			exFunc:   "team_a_mask",
+			expected: `{"success":true,"num_records":1,"results":["team_a:mask"]}`,
+		},
+		{
+			exFunc:   "Analytics.TEAM_A_mask",
+			expected: `{"success":true,"num_records":1,"results":["team_a:Analytics.mask"]}`,
+		},
+		{
+			exFunc:   "TEAM_A_MASK",
+			expected: `{"success":true,"num_records":1,"results":["team_a:MASK"]}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.exFunc, func(t *testing.T) {
+			event := testLambdaUDFEvent(c.exFunc, [][]interface{}{{1}})
+			actual, err := mux.HandleLambdaEvent(context.Background(), event)
+			require.NoError(t, err)
+			require.JSONEq(t, c.expected, actual)
+		})
+	}
+}
+
+func TestMuxGroup(t *testing.T) {
+	mux := gravita.NewMux()
+	group := mux.Group(gravita.DatabaseMatcher("analytics"))
+	group.HandleRowFunc("analytics_*", func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return "analytics", nil
+	})
+	group.NotMatchHandler = gravita.LambdaUDFHandlerFunc(func(_ context.Context, _ [][]interface{}) ([]interface{}, error) {
+		return []interface{}{"group not match"}, nil
+	})
+	mux.HandleRowFunc("*", func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return "default", nil
+	})
+
+	cases := []struct {
+		database string
+		exFunc   string
+		expected string
+	}{
+		{database: "dev", exFunc: "analytics_udf", expected: "default"},
+		{database: "analytics", exFunc: "analytics_udf", expected: "analytics"},
+		{database: "analytics", exFunc: "test_udf", expected: "group not match"},
+	}
+	for _, c := range cases {
+		t.Run(c.database+"/"+c.exFunc, func(t *testing.T) {
+			event := testLambdaUDFEvent(c.exFunc, [][]interface{}{{1}})
+			event.Database = c.database
+			actual, err := mux.HandleLambdaEvent(context.Background(), event)
+			require.NoError(t, err)
+			require.JSONEq(t, `{"success":true,"num_records":1,"results":["`+c.expected+`"]}`, actual)
+		})
+	}
+}
+
+func TestMuxGroupInheritsSettings(t *testing.T) {
+	var records []*gravita.AuditRecord
+	var letters []*gravita.DeadLetter
+	var buf bytes.Buffer
+	mux := gravita.NewMux()
+	mux.AuditSink = gravita.AuditSinkFunc(func(_ context.Context, record *gravita.AuditRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	mux.AuditHashKey = []byte("secret")
+	mux.DeadLetterSink = gravita.DeadLetterSinkFunc(func(_ context.Context, letter *gravita.DeadLetter) error {
+		letters = append(letters, letter)
+		return nil
+	})
+	mux.Logger = log.New(&buf, "", 0)
+	mux.Switches = gravita.NewSwitchBoard()
+	echo := func(_ context.Context, args []interface{}) (interface{}, error) {
+		return args[0], nil
+	}
+	mounted := gravita.NewMux()
+	mounted.HandleRowFunc("geocode", echo).Name("mounted_geocode")
+	mux.Mount("team_a_", mounted)
+	group := mux.Group(gravita.DatabaseMatcher("analytics"))
+	group.HandleRowFunc("mask", echo).Name("grouped_mask").Audit(gravita.AuditArgumentsHashed)
+	group.HandleRowFunc("old_mask", echo).Deprecated("use mask instead", time.Time{})
+
+	invoke := func(m *gravita.Mux, exFunc string) string {
+		t.Helper()
+		event := testLambdaUDFEvent(exFunc, [][]interface{}{{"a@example.com"}})
+		event.Database = "analytics"
+		actual, err := m.HandleLambdaEvent(context.Background(), event)
+		require.NoError(t, err)
+		return actual
+	}
+
+	require.JSONEq(t, `{"success":true,"num_records":1,"results":["a@example.com"]}`, invoke(mux, "mask"))
+	require.Len(t, records, 1, "AuditSink")
+	require.True(t, strings.HasPrefix(records[0].Arguments[0][0].(string), "hmac-sha256:"), "AuditHashKey")
+
+	invoke(mux, "old_mask")
+	require.Contains(t, buf.String(), "external function `old_mask` is deprecated", "Logger")
+
+	mux.Switches.Disable("grouped_mask", "mask is down")
+	mux.Switches.Disable("mounted_geocode", "geocode is down")
+	require.JSONEq(t, `{"success":false,"error_msg":"mask is down"}`, invoke(mux, "mask"), "Switches")
+	require.JSONEq(t, `{"success":false,"error_msg":"geocode is down"}`, invoke(mux, "team_a_geocode"), "Switches of Mount")
+
+	letters = nil
+	invoke(group, "mask")
+	require.Len(t, letters, 1, "DeadLetterSink")
+}
+
+func TestMuxChildIntrospection(t *testing.T) {
+	noop := func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	mux := gravita.NewMux()
+	mux.Logger = log.New(io.Discard, "", 0)
+	group := mux.Group(gravita.DatabaseMatcher("analytics"))
+	group.HandleRowFunc("hash", noop).Name("hash").Signature(gravita.Signature{
+		Arguments: []gravita.SQLType{gravita.SQLVarchar},
+		Returns:   gravita.SQLVarchar,
+	})
+	group.NewEntry().ExternalFunction("broken")
+	teamA := gravita.NewMux()
+	teamA.HandleRowFunc("mask", noop).Signature(gravita.Signature{
+		Arguments: []gravita.SQLType{gravita.SQLVarchar},
+		Returns:   gravita.SQLVarchar,
+	})
+	mux.Mount("team_a_", teamA)
+
+	ddl, err := mux.DDL(gravita.DDLOptions{LambdaName: "udf-function"})
+	require.NoError(t, err)
+	require.Contains(t, ddl, "CREATE OR REPLACE EXTERNAL FUNCTION hash(VARCHAR)\n")
+	require.Contains(t, ddl, "CREATE OR REPLACE EXTERNAL FUNCTION team_a_mask(VARCHAR)\n")
+
+	err = mux.Validate()
+	require.EqualError(t, err, "invalid mux: entry[0]/entry[1]: handler is nil")
+
+	labels := make([]string, 0)
+	for _, d := range mux.Entries() {
+		labels = append(labels, d.String())
+	}
+	require.Equal(t, []string{`entry[0]`, `entry[0]/entry[0] "hash"`, `entry[0]/entry[1]`, `entry[1]`, `entry[1]/entry[0]`}, labels)
+
+	event := testLambdaUDFEvent("team_a_mask", nil)
+	ex := mux.Explain(event)
+	require.Equal(t, "entry[1]", ex.Chosen.String())
+	require.NotNil(t, ex.Delegated)
+	require.Equal(t, "mask", ex.Delegated.ExternalFunction)
+	require.Equal(t, "entry[1]/entry[0]", ex.Delegated.Chosen.String())
+	require.Contains(t, ex.String(), "\n  external function `mask`:\n    entry[1]/entry[0]: matched")
+}
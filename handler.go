@@ -34,6 +34,8 @@ func (f LambdaUDFRowHandlerFunc) ExecuteUDFRow(ctx context.Context, args []inter
 // ParallelRowProcessHandler is a LambdaUDFHandler that can be used when each row is independent and processes rows in parallel
 type ParallelRowProcessHandler struct {
 	RowHandler LambdaUDFRowHandler
+	// MaxConcurrency limits the number of rows processed at the same time. 0 means unlimited
+	MaxConcurrency int
 }
 
 func (h ParallelRowProcessHandler) ExecuteUDF(ctx context.Context, args [][]interface{}) ([]interface{}, error) {
@@ -44,6 +46,9 @@ func (h ParallelRowProcessHandler) ExecuteUDF(ctx context.Context, args [][]inte
 	}
 
 	var g errgroup.Group
+	if h.MaxConcurrency > 0 {
+		g.SetLimit(h.MaxConcurrency)
+	}
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	for i := 0; i < n; i++ {
@@ -65,10 +70,11 @@ func (h ParallelRowProcessHandler) ExecuteUDF(ctx context.Context, args [][]inte
 }
 
 type BatchProcessHandler struct {
-	handler       LambdaUDFHandler
-	distinct      bool
-	batchSize     int
-	maxBatchCount *int
+	handler        LambdaUDFHandler
+	distinct       bool
+	batchSize      int
+	maxBatchCount  *int
+	maxConcurrency int
 }
 
 func NewBatchProcessHandler(batchSize int, handler LambdaUDFHandler) *BatchProcessHandler {
@@ -91,6 +97,31 @@ func (h *BatchProcessHandler) MaxBatchCount(m int) {
 	h.maxBatchCount = &m
 }
 
+// MaxConcurrency limits the number of batches processed at the same time. 0 means unlimited
+func (h *BatchProcessHandler) MaxConcurrency(n int) {
+	h.maxConcurrency = n
+}
+
+func (h *BatchProcessHandler) GetDistinct() bool {
+	return h.distinct
+}
+
+func (h *BatchProcessHandler) GetBatchSize() int {
+	return h.batchSize
+}
+
+// GetMaxBatchCount returns the max batch count, and false if it is not limited
+func (h *BatchProcessHandler) GetMaxBatchCount() (int, bool) {
+	if h.maxBatchCount == nil {
+		return 0, false
+	}
+	return *h.maxBatchCount, true
+}
+
+func (h *BatchProcessHandler) GetMaxConcurrency() int {
+	return h.maxConcurrency
+}
+
 func (h *BatchProcessHandler) ExecuteUDF(ctx context.Context, args [][]interface{}) ([]interface{}, error) {
 	results := make([]interface{}, len(args))
 
@@ -116,6 +147,9 @@ func (h *BatchProcessHandler) ExecuteUDF(ctx context.Context, args [][]interface
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	var g errgroup.Group
+	if h.maxConcurrency > 0 {
+		g.SetLimit(h.maxConcurrency)
+	}
 	batchCount := 0
 	for i := h.batchSize; len(batchArgs) > 0; {
 		if len(batchArgs) < h.batchSize {
@@ -0,0 +1,68 @@
+package gravita
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// JSONType represents the type of a JSON value passed in arguments
+type JSONType int
+
+// JSON types
+const (
+	JSONAny JSONType = iota
+	JSONNull
+	JSONBoolean
+	JSONNumber
+	JSONString
+	JSONArray
+	JSONObject
+)
+
+func (t JSONType) String() string {
+	switch t {
+	case JSONNull:
+		return "null"
+	case JSONBoolean:
+		return "boolean"
+	case JSONNumber:
+		return "number"
+	case JSONString:
+		return "string"
+	case JSONArray:
+		return "array"
+	case JSONObject:
+		return "object"
+	}
+	return "any"
+}
+
+// JSONTypeOf returns the JSONType of an argument value
+func JSONTypeOf(v interface{}) JSONType {
+	switch v.(type) {
+	case nil:
+		return JSONNull
+	case bool:
+		return JSONBoolean
+	case string:
+		return JSONString
+	case json.Number, float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return JSONNumber
+	case []interface{}:
+		return JSONArray
+	case map[string]interface{}:
+		return JSONObject
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Slice, reflect.Array:
+		return JSONArray
+	case reflect.Map, reflect.Struct:
+		return JSONObject
+	}
+	return JSONAny
+}
+
+// Accepts reports whether a value of JSONType u is acceptable as t. JSONAny accepts any type
+func (t JSONType) Accepts(u JSONType) bool {
+	return t == JSONAny || t == u
+}
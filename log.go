@@ -0,0 +1,21 @@
+package gravita
+
+import "log"
+
+// Logger is the interface used by Mux, SwitchBoard and ShadowHandler to write logs. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// logf writes the log by logger, or by the standard logger if logger is nil
+func logf(logger Logger, format string, v ...interface{}) {
+	if logger != nil {
+		logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+func (mux *Mux) logf(format string, v ...interface{}) {
+	logf(mux.logger(), format, v...)
+}
@@ -1,17 +1,109 @@
 package gravita
 
 import (
+	"math"
 	"regexp"
 	"strings"
 )
 
-type matcher interface {
+// Matcher is the interface of a condition that routes LambdaUDFEvent to Entry
+type Matcher interface {
 	Match(event *LambdaUDFEvent) bool
 }
 
-func (e *Entry) addMatcher(m matcher) *Entry {
-	e.matchers = append(e.matchers, m)
-	return e
+// MatcherFunc is a type of function that satisfies Matcher
+type MatcherFunc func(event *LambdaUDFEvent) bool
+
+// Match calls f(event)
+func (f MatcherFunc) Match(event *LambdaUDFEvent) bool {
+	return f(event)
+}
+
+func (e *Entry) addMatcher(m Matcher) *Entry {
+	return e.update(func(s *entryState) {
+		s.matchers = append(s.matchers, m)
+	})
+}
+
+// MatcherOrError adds the matcher returned by a New*Matcher constructor, such as
+// e.MatcherOrError(gravita.NewUserRegexpMatcher(expr)), without panicking on an invalid pattern.
+// If err is not nil, it is recorded in the Entry, the Entry never matches, and the error is reported by Entry.Err and Mux.Validate.
+func (e *Entry) MatcherOrError(m Matcher, err error) *Entry {
+	return e.update(func(s *entryState) {
+		if err != nil {
+			s.errs = append(s.errs, err)
+			m = invalidMatcher{}
+		}
+		s.matchers = append(s.matchers, m)
+	})
+}
+
+func mustMatcher(m Matcher, err error) Matcher {
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// invalidMatcher is a Matcher that never matches, in place of an invalid pattern
+type invalidMatcher struct{}
+
+func (invalidMatcher) Match(_ *LambdaUDFEvent) bool {
+	return false
+}
+
+// Matcher adds matchers to Entry. All matchers of the Entry must match
+func (e *Entry) Matcher(matchers ...Matcher) *Entry {
+	return e.update(func(s *entryState) {
+		s.matchers = append(s.matchers, matchers...)
+	})
+}
+
+// ---- Combinators ----
+
+type andMatcher []Matcher
+
+func (m andMatcher) Match(event *LambdaUDFEvent) bool {
+	for _, c := range m {
+		if !c.Match(event) {
+			return false
+		}
+	}
+	return true
+}
+
+// And returns a Matcher that matches if all matchers match
+func And(matchers ...Matcher) Matcher {
+	return andMatcher(matchers)
+}
+
+type orMatcher []Matcher
+
+func (m orMatcher) Match(event *LambdaUDFEvent) bool {
+	for _, c := range m {
+		if c.Match(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// Or returns a Matcher that matches if any of matchers matches
+func Or(matchers ...Matcher) Matcher {
+	return orMatcher(matchers)
+}
+
+type notMatcher struct {
+	Matcher
+}
+
+func (m notMatcher) Match(event *LambdaUDFEvent) bool {
+	return !m.Matcher.Match(event)
+}
+
+// Not returns a Matcher that matches if the matcher does not match
+func Not(matcher Matcher) Matcher {
+	return notMatcher{matcher}
 }
 
 // matchAllMacher is a Macher that matches any criteria
@@ -23,23 +115,93 @@ func (_ matchAllMacher) Match(_ *LambdaUDFEvent) bool {
 
 // ---- ExternalFunction ----
 
-// externalFunctionMatcher matches the event external_function value
-type externalFunctionMatcher string
+// externalFunctionMatcher matches the event external_function value with an exact name or a glob pattern.
+// If the pattern is not schema-qualified, it also matches the function name part of a schema-qualified name.
+type externalFunctionMatcher struct {
+	pattern   string
+	glob      *glob
+	qualified bool
+	fold      bool
+}
+
+func newExternalFunctionMatcher(pattern string, fold bool) (*externalFunctionMatcher, error) {
+	m := &externalFunctionMatcher{
+		pattern:   pattern,
+		qualified: ParseExternalFunction(pattern).Schema != "",
+		fold:      fold,
+	}
+	if hasGlobMeta(pattern) {
+		if fold {
+			pattern = strings.ToLower(pattern)
+		}
+		g, err := compileGlob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		m.glob = g
+	}
+	return m, nil
+}
+
+// folded returns the copy of the matcher that matches case-insensitively
+func (m *externalFunctionMatcher) folded() *externalFunctionMatcher {
+	f, err := newExternalFunctionMatcher(m.pattern, true)
+	if err != nil {
+		return m
+	}
+	return f
+}
 
 // Match the event external_function value
-func (m externalFunctionMatcher) Match(event *LambdaUDFEvent) bool {
-	return string(m) == event.ExternalFunction
+func (m *externalFunctionMatcher) Match(event *LambdaUDFEvent) bool {
+	if m.matchName(event.ExternalFunction) {
+		return true
+	}
+	name := ParseExternalFunction(event.ExternalFunction)
+	if m.qualified {
+		return name.Schema != "" && m.matchName(name.String())
+	}
+	return name.Schema != "" && m.matchName(name.Function)
 }
 
-// ExternalFunction matches by LambdaUDF function name. You can use * as a wildcard
-func (e *Entry) ExternalFunction(exFunc string) *Entry {
-	if exFunc == "*" {
-		return e.addMatcher(matchAllMacher{})
+func (m *externalFunctionMatcher) matchName(name string) bool {
+	if m.glob != nil {
+		if m.fold {
+			name = strings.ToLower(name)
+		}
+		return m.glob.MatchString(name)
 	}
-	if strings.ContainsRune(exFunc, '*') {
-		return e.ExternalFunctionRegexp(strings.ReplaceAll(exFunc, "*", ".*"))
+	if m.fold {
+		return strings.EqualFold(m.pattern, name)
+	}
+	return m.pattern == name
+}
+
+// NewExternalFunctionMatcher returns a Matcher by LambdaUDF function name, or an error if the glob pattern is invalid.
+// The glob pattern (*, ?, [...] and \ escape) must match the whole name.
+// A pattern that is not schema-qualified also matches the function of any schema, and a schema-qualified pattern such as `analytics.mask` matches only the schema.
+func NewExternalFunctionMatcher(exFunc string) (Matcher, error) {
+	return externalFunctionMatcherOf(exFunc, false)
+}
+
+// ExternalFunctionMatcher is like NewExternalFunctionMatcher but panics if the glob pattern is invalid
+func ExternalFunctionMatcher(exFunc string) Matcher {
+	return mustMatcher(NewExternalFunctionMatcher(exFunc))
+}
+
+func externalFunctionMatcherOf(exFunc string, fold bool) (Matcher, error) {
+	if exFunc == "*" {
+		return matchAllMacher{}, nil
 	}
-	return e.addMatcher(externalFunctionMatcher(exFunc))
+	return newExternalFunctionMatcher(exFunc, fold)
+}
+
+// ExternalFunction matches by LambdaUDF function name. The glob pattern (*, ?, [...] and \ escape) must match the whole name.
+// A pattern that is not schema-qualified also matches the function of any schema, and a schema-qualified pattern such as `analytics.mask` matches only the schema.
+// If Mux.CaseInsensitive is enabled, the name is matched case-insensitively.
+// It panics if the pattern is invalid, see MatcherOrError to handle the error.
+func (e *Entry) ExternalFunction(exFunc string) *Entry {
+	return e.addMatcher(mustMatcher(NewExternalFunctionMatcher(exFunc)))
 }
 
 type externalFunctionRegexpMatcher regexp.Regexp
@@ -49,10 +211,19 @@ func (m *externalFunctionRegexpMatcher) Match(event *LambdaUDFEvent) bool {
 	return (*regexp.Regexp)(m).MatchString(event.ExternalFunction)
 }
 
+// NewExternalFunctionRegexpMatcher returns a Matcher by LambdaUDF function name with a regular expression, or an error if expr is invalid
+func NewExternalFunctionRegexpMatcher(expr string) (Matcher, error) {
+	re, err := regexp.CompilePOSIX(expr)
+	if err != nil {
+		return nil, err
+	}
+	return (*externalFunctionRegexpMatcher)(re), nil
+}
+
 // ExternalFunctionRegexp matches a LambdaUDF function name with a regular expression
+// It panics if the pattern is invalid, see MatcherOrError to handle the error.
 func (e *Entry) ExternalFunctionRegexp(expr string) *Entry {
-	re := regexp.MustCompilePOSIX(expr)
-	return e.addMatcher((*externalFunctionRegexpMatcher)(re))
+	return e.addMatcher(mustMatcher(NewExternalFunctionRegexpMatcher(expr)))
 }
 
 // ---- User ----
@@ -65,15 +236,39 @@ func (m userMatcher) Match(event *LambdaUDFEvent) bool {
 	return string(m) == event.User
 }
 
-// User matches by LambdaUDF user name. You can use * as a wildcard
-func (e *Entry) User(user string) *Entry {
+// NewUserMatcher returns a Matcher by LambdaUDF user name, or an error if the glob pattern is invalid.
+// The glob pattern (*, ?, [...] and \ escape) must match the whole name
+func NewUserMatcher(user string) (Matcher, error) {
 	if user == "*" {
-		return e.addMatcher(matchAllMacher{})
+		return matchAllMacher{}, nil
 	}
-	if strings.ContainsRune(user, '*') {
-		return e.UserRegexp(strings.ReplaceAll(user, "*", ".*"))
+	if hasGlobMeta(user) {
+		g, err := compileGlob(user)
+		if err != nil {
+			return nil, err
+		}
+		return (*userGlobMatcher)(g), nil
 	}
-	return e.addMatcher(userMatcher(user))
+	return userMatcher(user), nil
+}
+
+// UserMatcher is like NewUserMatcher but panics if the glob pattern is invalid
+func UserMatcher(user string) Matcher {
+	return mustMatcher(NewUserMatcher(user))
+}
+
+// User matches by LambdaUDF user name. The glob pattern (*, ?, [...] and \ escape) must match the whole name
+// It panics if the pattern is invalid, see MatcherOrError to handle the error.
+func (e *Entry) User(user string) *Entry {
+	return e.addMatcher(mustMatcher(NewUserMatcher(user)))
+}
+
+// userGlobMatcher matches the event user value with a glob pattern
+type userGlobMatcher glob
+
+// Match the event user value
+func (m *userGlobMatcher) Match(event *LambdaUDFEvent) bool {
+	return (*glob)(m).MatchString(event.User)
 }
 
 type userRegexpMatcher regexp.Regexp
@@ -83,10 +278,19 @@ func (m *userRegexpMatcher) Match(event *LambdaUDFEvent) bool {
 	return (*regexp.Regexp)(m).MatchString(event.User)
 }
 
+// NewUserRegexpMatcher returns a Matcher by LambdaUDF user name with a regular expression, or an error if expr is invalid
+func NewUserRegexpMatcher(expr string) (Matcher, error) {
+	re, err := regexp.CompilePOSIX(expr)
+	if err != nil {
+		return nil, err
+	}
+	return (*userRegexpMatcher)(re), nil
+}
+
 // UserRegexp matches a LambdaUDF user name with a regular expression
+// It panics if the pattern is invalid, see MatcherOrError to handle the error.
 func (e *Entry) UserRegexp(expr string) *Entry {
-	re := regexp.MustCompilePOSIX(expr)
-	return e.addMatcher((*userRegexpMatcher)(re))
+	return e.addMatcher(mustMatcher(NewUserRegexpMatcher(expr)))
 }
 
 // ---- Cluster ----
@@ -99,15 +303,39 @@ func (m clusterMatcher) Match(event *LambdaUDFEvent) bool {
 	return string(m) == event.Cluster
 }
 
-// Cluster matches by LambdaUDF cluster name. You can use * as a wildcard
-func (e *Entry) Cluster(cluster string) *Entry {
+// NewClusterMatcher returns a Matcher by LambdaUDF cluster name, or an error if the glob pattern is invalid.
+// The glob pattern (*, ?, [...] and \ escape) must match the whole name
+func NewClusterMatcher(cluster string) (Matcher, error) {
 	if cluster == "*" {
-		return e.addMatcher(matchAllMacher{})
+		return matchAllMacher{}, nil
 	}
-	if strings.ContainsRune(cluster, '*') {
-		return e.ClusterRegexp(strings.ReplaceAll(cluster, "*", ".*"))
+	if hasGlobMeta(cluster) {
+		g, err := compileGlob(cluster)
+		if err != nil {
+			return nil, err
+		}
+		return (*clusterGlobMatcher)(g), nil
 	}
-	return e.addMatcher(clusterMatcher(cluster))
+	return clusterMatcher(cluster), nil
+}
+
+// ClusterMatcher is like NewClusterMatcher but panics if the glob pattern is invalid
+func ClusterMatcher(cluster string) Matcher {
+	return mustMatcher(NewClusterMatcher(cluster))
+}
+
+// Cluster matches by LambdaUDF cluster name. The glob pattern (*, ?, [...] and \ escape) must match the whole name
+// It panics if the pattern is invalid, see MatcherOrError to handle the error.
+func (e *Entry) Cluster(cluster string) *Entry {
+	return e.addMatcher(mustMatcher(NewClusterMatcher(cluster)))
+}
+
+// clusterGlobMatcher matches the event cluster value with a glob pattern
+type clusterGlobMatcher glob
+
+// Match the event cluster value
+func (m *clusterGlobMatcher) Match(event *LambdaUDFEvent) bool {
+	return (*glob)(m).MatchString(event.Cluster)
 }
 
 type clusterRegexpMatcher regexp.Regexp
@@ -117,10 +345,19 @@ func (m *clusterRegexpMatcher) Match(event *LambdaUDFEvent) bool {
 	return (*regexp.Regexp)(m).MatchString(event.Cluster)
 }
 
+// NewClusterRegexpMatcher returns a Matcher by LambdaUDF cluster name with a regular expression, or an error if expr is invalid
+func NewClusterRegexpMatcher(expr string) (Matcher, error) {
+	re, err := regexp.CompilePOSIX(expr)
+	if err != nil {
+		return nil, err
+	}
+	return (*clusterRegexpMatcher)(re), nil
+}
+
 // ClusterRegexp matches a LambdaUDF cluster name with a regular expression
+// It panics if the pattern is invalid, see MatcherOrError to handle the error.
 func (e *Entry) ClusterRegexp(expr string) *Entry {
-	re := regexp.MustCompilePOSIX(expr)
-	return e.addMatcher((*clusterRegexpMatcher)(re))
+	return e.addMatcher(mustMatcher(NewClusterRegexpMatcher(expr)))
 }
 
 // ---- Database ----
@@ -133,15 +370,39 @@ func (m databaseMatcher) Match(event *LambdaUDFEvent) bool {
 	return string(m) == event.Database
 }
 
-// Database matches by LambdaUDF database name. You can use * as a wildcard
-func (e *Entry) Database(database string) *Entry {
+// NewDatabaseMatcher returns a Matcher by LambdaUDF database name, or an error if the glob pattern is invalid.
+// The glob pattern (*, ?, [...] and \ escape) must match the whole name
+func NewDatabaseMatcher(database string) (Matcher, error) {
 	if database == "*" {
-		return e.addMatcher(matchAllMacher{})
+		return matchAllMacher{}, nil
 	}
-	if strings.ContainsRune(database, '*') {
-		return e.DatabaseRegexp(strings.ReplaceAll(database, "*", ".*"))
+	if hasGlobMeta(database) {
+		g, err := compileGlob(database)
+		if err != nil {
+			return nil, err
+		}
+		return (*databaseGlobMatcher)(g), nil
 	}
-	return e.addMatcher(databaseMatcher(database))
+	return databaseMatcher(database), nil
+}
+
+// DatabaseMatcher is like NewDatabaseMatcher but panics if the glob pattern is invalid
+func DatabaseMatcher(database string) Matcher {
+	return mustMatcher(NewDatabaseMatcher(database))
+}
+
+// Database matches by LambdaUDF database name. The glob pattern (*, ?, [...] and \ escape) must match the whole name
+// It panics if the pattern is invalid, see MatcherOrError to handle the error.
+func (e *Entry) Database(database string) *Entry {
+	return e.addMatcher(mustMatcher(NewDatabaseMatcher(database)))
+}
+
+// databaseGlobMatcher matches the event database value with a glob pattern
+type databaseGlobMatcher glob
+
+// Match the event database value
+func (m *databaseGlobMatcher) Match(event *LambdaUDFEvent) bool {
+	return (*glob)(m).MatchString(event.Database)
 }
 
 type databaseRegexpMatcher regexp.Regexp
@@ -151,8 +412,198 @@ func (m *databaseRegexpMatcher) Match(event *LambdaUDFEvent) bool {
 	return (*regexp.Regexp)(m).MatchString(event.Database)
 }
 
+// NewDatabaseRegexpMatcher returns a Matcher by LambdaUDF database name with a regular expression, or an error if expr is invalid
+func NewDatabaseRegexpMatcher(expr string) (Matcher, error) {
+	re, err := regexp.CompilePOSIX(expr)
+	if err != nil {
+		return nil, err
+	}
+	return (*databaseRegexpMatcher)(re), nil
+}
+
 // DatabaseRegexp matches a LambdaUDF database name with a regular expression
+// It panics if the pattern is invalid, see MatcherOrError to handle the error.
 func (e *Entry) DatabaseRegexp(expr string) *Entry {
-	re := regexp.MustCompilePOSIX(expr)
-	return e.addMatcher((*databaseRegexpMatcher)(re))
+	return e.addMatcher(mustMatcher(NewDatabaseRegexpMatcher(expr)))
+}
+
+// ---- RequestID ----
+
+// requestIDMatcher matches the event request_id value
+type requestIDMatcher string
+
+// Match the event request_id value
+func (m requestIDMatcher) Match(event *LambdaUDFEvent) bool {
+	return string(m) == event.RequestID
+}
+
+// NewRequestIDMatcher returns a Matcher by LambdaUDF request id, or an error if the glob pattern is invalid.
+// You can use glob patterns (*, ?, [...] and \ escape)
+func NewRequestIDMatcher(requestID string) (Matcher, error) {
+	if requestID == "*" {
+		return matchAllMacher{}, nil
+	}
+	if hasGlobMeta(requestID) {
+		g, err := compileGlob(requestID)
+		if err != nil {
+			return nil, err
+		}
+		return (*requestIDGlobMatcher)(g), nil
+	}
+	return requestIDMatcher(requestID), nil
+}
+
+// RequestIDMatcher is like NewRequestIDMatcher but panics if the glob pattern is invalid
+func RequestIDMatcher(requestID string) Matcher {
+	return mustMatcher(NewRequestIDMatcher(requestID))
+}
+
+// RequestID matches by LambdaUDF request id. You can use glob patterns (*, ?, [...] and \ escape)
+// It panics if the pattern is invalid, see MatcherOrError to handle the error.
+func (e *Entry) RequestID(requestID string) *Entry {
+	return e.addMatcher(mustMatcher(NewRequestIDMatcher(requestID)))
+}
+
+// requestIDGlobMatcher matches the event request_id value with a glob pattern
+type requestIDGlobMatcher glob
+
+// Match the event request_id value
+func (m *requestIDGlobMatcher) Match(event *LambdaUDFEvent) bool {
+	return (*glob)(m).MatchString(event.RequestID)
+}
+
+// ---- QueryID ----
+
+// queryIDRangeMatcher matches the event query_id value in range
+type queryIDRangeMatcher struct {
+	min, max int
+}
+
+// Match the event query_id value
+func (m queryIDRangeMatcher) Match(event *LambdaUDFEvent) bool {
+	return m.min <= event.QueryID && event.QueryID <= m.max
+}
+
+// QueryIDRangeMatcher returns a Matcher by LambdaUDF query id in [min, max]
+func QueryIDRangeMatcher(min, max int) Matcher {
+	return queryIDRangeMatcher{min: min, max: max}
+}
+
+// QueryIDRange matches by LambdaUDF query id in [min, max]
+func (e *Entry) QueryIDRange(min, max int) *Entry {
+	return e.addMatcher(QueryIDRangeMatcher(min, max))
+}
+
+// ---- NumRecords ----
+
+// numRecordsRangeMatcher matches the event num_records value in range
+type numRecordsRangeMatcher struct {
+	min, max int
+}
+
+// Match the event num_records value
+func (m numRecordsRangeMatcher) Match(event *LambdaUDFEvent) bool {
+	return m.min <= event.NumRecords && event.NumRecords <= m.max
+}
+
+// NumRecordsRangeMatcher returns a Matcher by the number of records in [min, max]
+func NumRecordsRangeMatcher(min, max int) Matcher {
+	return numRecordsRangeMatcher{min: min, max: max}
+}
+
+// NumRecordsAtLeast matches if the number of records is greater than or equal to n.
+// e.g. route huge batches to a batch handler
+func (e *Entry) NumRecordsAtLeast(n int) *Entry {
+	return e.addMatcher(NumRecordsRangeMatcher(n, math.MaxInt32))
+}
+
+// NumRecordsAtMost matches if the number of records is less than or equal to n
+func (e *Entry) NumRecordsAtMost(n int) *Entry {
+	return e.addMatcher(NumRecordsRangeMatcher(0, n))
+}
+
+// ---- Arguments ----
+
+// numArgumentsMatcher matches the number of arguments in each row
+type numArgumentsMatcher int
+
+// Match the number of arguments in each row
+func (m numArgumentsMatcher) Match(event *LambdaUDFEvent) bool {
+	for _, rowArgs := range event.Arguments {
+		if len(rowArgs) != int(m) {
+			return false
+		}
+	}
+	return true
+}
+
+// NumArguments matches by the number of arguments of LambdaUDF, to route overloaded functions
+func (e *Entry) NumArguments(n int) *Entry {
+	return e.addMatcher(numArgumentsMatcher(n))
+}
+
+// argumentTypesMatcher matches the JSON types of arguments in each row
+type argumentTypesMatcher []JSONType
+
+// Match the JSON types of arguments in each row
+func (m argumentTypesMatcher) Match(event *LambdaUDFEvent) bool {
+	for _, rowArgs := range event.Arguments {
+		if len(rowArgs) != len(m) {
+			return false
+		}
+		for i, v := range rowArgs {
+			if v == nil {
+				continue
+			}
+			if !m[i].Accepts(JSONTypeOf(v)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ArgumentTypes matches by the JSON types of LambdaUDF arguments, to route overloaded functions.
+// The number of arguments must equal to the number of types. NULL matches any type, and JSONAny matches any value
+func (e *Entry) ArgumentTypes(types ...JSONType) *Entry {
+	m := make(argumentTypesMatcher, len(types))
+	copy(m, types)
+	return e.addMatcher(m)
+}
+
+// argumentValueMatcher matches the argument value of a column in each row
+type argumentValueMatcher struct {
+	column    int
+	predicate func(interface{}) bool
+}
+
+// Match the argument value of the column in each row
+func (m argumentValueMatcher) Match(event *LambdaUDFEvent) bool {
+	for _, rowArgs := range event.Arguments {
+		if m.column < 0 || m.column >= len(rowArgs) {
+			return false
+		}
+		if !m.predicate(rowArgs[m.column]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ArgumentValueMatcher returns a Matcher that matches if the argument value of the column (0-origin) satisfies predicate in all rows
+func ArgumentValueMatcher(column int, predicate func(v interface{}) bool) Matcher {
+	return argumentValueMatcher{column: column, predicate: predicate}
+}
+
+// ArgumentValue matches if the argument value of the column (0-origin) satisfies predicate in all rows
+func (e *Entry) ArgumentValue(column int, predicate func(v interface{}) bool) *Entry {
+	return e.addMatcher(ArgumentValueMatcher(column, predicate))
+}
+
+// ArgumentHasPrefix matches if the argument value of the column (0-origin) is a string that starts with prefix in all rows
+func (e *Entry) ArgumentHasPrefix(column int, prefix string) *Entry {
+	return e.ArgumentValue(column, func(v interface{}) bool {
+		str, ok := v.(string)
+		return ok && strings.HasPrefix(str, prefix)
+	})
 }
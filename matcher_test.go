@@ -0,0 +1,164 @@
+package gravita_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobPattern(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{pattern: "foo", name: "foo", expected: true},
+		{pattern: "foo", name: "xfoo", expected: false},
+		{pattern: "*foo*", name: "xfooy", expected: true},
+		{pattern: "f.o*", name: "fxo_bar", expected: false},
+		{pattern: "f.o*", name: "f.o_bar", expected: true},
+		{pattern: "foo*", name: "xfoo_bar", expected: false},
+		{pattern: "f?o", name: "fxo", expected: true},
+		{pattern: "f?o", name: "fo", expected: false},
+		{pattern: "udf_[0-9]", name: "udf_7", expected: true},
+		{pattern: "udf_[0-9]", name: "udf_x", expected: false},
+		{pattern: "udf_[!0-9]", name: "udf_x", expected: true},
+		{pattern: "udf_[^0-9]", name: "udf_7", expected: false},
+		{pattern: "udf_[a-]", name: "udf_-", expected: true},
+		{pattern: "udf_[]]", name: "udf_]", expected: true},
+		{pattern: `udf_\*`, name: "udf_*", expected: true},
+		{pattern: `udf_\*`, name: "udf_x", expected: false},
+		{pattern: "a+b(c)", name: "a+b(c)", expected: true},
+		{pattern: "a+b(c)*", name: "aab(c)", expected: false},
+	}
+	for _, c := range cases {
+		t.Run(c.pattern+"_"+c.name, func(t *testing.T) {
+			event := testLambdaUDFEvent(c.name, nil)
+			event.User = c.name
+			event.Cluster = c.name
+			event.Database = c.name
+			event.RequestID = c.name
+			for _, m := range []gravita.Matcher{
+				gravita.ExternalFunctionMatcher(c.pattern),
+				gravita.UserMatcher(c.pattern),
+				gravita.ClusterMatcher(c.pattern),
+				gravita.DatabaseMatcher(c.pattern),
+				gravita.RequestIDMatcher(c.pattern),
+			} {
+				require.Equal(t, c.expected, m.Match(event))
+			}
+		})
+	}
+}
+
+func TestGlobPatternInvalid(t *testing.T) {
+	require.Panics(t, func() {
+		gravita.ExternalFunctionMatcher("udf_[0-9")
+	})
+	require.Panics(t, func() {
+		gravita.ExternalFunctionMatcher(`udf_\`)
+	})
+	require.Panics(t, func() {
+		gravita.NewMux().NewEntry().ExternalFunction("udf_[0-9")
+	})
+	require.Panics(t, func() {
+		gravita.NewMux().NewEntry().UserRegexp("(")
+	})
+}
+
+func TestParseExternalFunction(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected gravita.ExternalFunctionName
+	}{
+		{name: "f_mask", expected: gravita.ExternalFunctionName{Function: "f_mask"}},
+		{name: "analytics.f_mask", expected: gravita.ExternalFunctionName{Schema: "analytics", Function: "f_mask"}},
+		{name: `"My.Schema"."f_""mask"`, expected: gravita.ExternalFunctionName{Schema: "My.Schema", Function: `f_"mask`}},
+		{name: "dev.analytics.f_mask", expected: gravita.ExternalFunctionName{Schema: "analytics", Function: "f_mask"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.expected, gravita.ParseExternalFunction(c.name))
+		})
+	}
+}
+
+func TestSchemaQualifiedExternalFunction(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{pattern: "mask", name: "analytics.mask", expected: true},
+		{pattern: "mask", name: "mask", expected: true},
+		{pattern: "analytics.mask", name: "analytics.mask", expected: true},
+		{pattern: "analytics.mask", name: "staging.mask", expected: false},
+		{pattern: "analytics.mask", name: "mask", expected: false},
+		{pattern: "analytics.mask", name: `"analytics"."mask"`, expected: true},
+		{pattern: "staging.*", name: "staging.mask", expected: true},
+		{pattern: "s*", name: "staging.mask", expected: true},
+		{pattern: "m*", name: "staging.mask", expected: true},
+	}
+	for _, c := range cases {
+		t.Run(c.pattern+"_"+c.name, func(t *testing.T) {
+			m := gravita.ExternalFunctionMatcher(c.pattern)
+			require.Equal(t, c.expected, m.Match(testLambdaUDFEvent(c.name, nil)))
+		})
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	mux := gravita.NewMux()
+	before := mux.NewEntry().ExternalFunction("Analytics.Mask")
+	mux.CaseInsensitive(true)
+	after := mux.NewEntry().ExternalFunction("Analytics.Mask")
+	globbed := mux.NewEntry().ExternalFunction("Analytics.M*")
+	unregistered := gravita.NewEntry().ExternalFunction("Analytics.Mask")
+
+	event := testLambdaUDFEvent("analytics.mask", nil)
+	require.True(t, before.Match(event), "entries registered before CaseInsensitive are also folded")
+	require.True(t, after.Match(event))
+	require.True(t, globbed.Match(event))
+	require.False(t, after.Match(testLambdaUDFEvent("staging.mask", nil)))
+	require.False(t, unregistered.Match(event))
+
+	mux.CaseInsensitive(false)
+	require.False(t, before.Match(event))
+	require.False(t, globbed.Match(event))
+}
+
+func TestCaseInsensitiveDispatch(t *testing.T) {
+	row := func(result string) func(context.Context, []interface{}) (interface{}, error) {
+		return func(_ context.Context, _ []interface{}) (interface{}, error) {
+			return result, nil
+		}
+	}
+	mux := gravita.NewMux()
+	mux.AddEntry(gravita.NewEntry().ExternalFunction("Mask").Handler(gravita.ParallelRowProcessHandler{
+		RowHandler: gravita.LambdaUDFRowHandlerFunc(row("added")),
+	}))
+	mux.Replace("geo", gravita.NewEntry().ExternalFunction("Geo_*").Handler(gravita.ParallelRowProcessHandler{
+		RowHandler: gravita.LambdaUDFRowHandlerFunc(row("replaced")),
+	}))
+	group := mux.Group(gravita.ExternalFunctionMatcher("analytics.*"))
+	group.HandleRowFunc("Analytics.Hash", row("grouped"))
+	mux.CaseInsensitive(true)
+
+	cases := []struct {
+		exFunc   string
+		expected string
+	}{
+		{exFunc: "mask", expected: "added"},
+		{exFunc: "geo_code", expected: "replaced"},
+		{exFunc: "ANALYTICS.hash", expected: "grouped"},
+	}
+	for _, c := range cases {
+		t.Run(c.exFunc, func(t *testing.T) {
+			actual, err := mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent(c.exFunc, [][]interface{}{{1}}))
+			require.NoError(t, err)
+			require.JSONEq(t, `{"success":true,"num_records":1,"results":["`+c.expected+`"]}`, actual)
+		})
+	}
+}
@@ -3,12 +3,38 @@ package gravita
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Mux struct {
 	NotMatchHandler LambdaUDFHandler
-	entries         []*Entry
+	// MostSpecificMatch selects the most specific entry among the entries with the same priority:
+	// an exact external function name beats a glob, a glob beats a regexp, and a regexp beats match-all.
+	// If false, the first matched entry in registration order is selected.
+	MostSpecificMatch bool
+	// TraceRouting logs the explanation of routing of each event, see Mux.Explain
+	TraceRouting bool
+	AuditSink    AuditSink
+	// AuditHashKey is the secret key of HMAC used by AuditArgumentsHashed, see AuditArgumentsHashed
+	AuditHashKey   []byte
+	Logger         Logger
+	DeadLetterSink DeadLetterSink
+	Recorder       *Recorder
+	// Switches blocks the invocations of disabled entries or entries in maintenance
+	Switches    *SwitchBoard
+	entries     []*Entry
+	middlewares []Middleware
+	// caseInsensitive is 1 if enabled by CaseInsensitive, accessed atomically
+	caseInsensitive int32
+	// parent is the Mux that this Mux is attached to by Group or Mount
+	parent *Mux
+
+	mu    sync.Mutex
+	table atomic.Value
 }
 
 func NewMux() *Mux {
@@ -27,79 +53,173 @@ func (mux *Mux) HandleLambdaEvent(ctx context.Context, event *LambdaUDFEvent) (j
 		if panicValue := recover(); panicValue != nil {
 			if err, ok := panicValue.(error); ok {
 				funcErr = err
+				mux.putDeadLetter(ctx, event, err.Error())
 			} else {
 				panic(panicValue)
 			}
 		}
 	}()
-	var handler LambdaUDFHandler
-	for _, e := range mux.entries {
-		if e.Match(event) {
-			handler = e.GetHandler()
-			if handler != nil {
-				break
-			}
+	output, err := mux.dispatch(ctx, event)
+	if err != nil {
+		return "", err
+	}
+	if !output.Success {
+		mux.putDeadLetter(ctx, event, output.ErrorMsg)
+	}
+	bs, err := json.Marshal(output)
+	if err != nil {
+		return "", err
+	}
+	jsonStr = string(bs)
+	if mux.Recorder != nil {
+		if err := mux.Recorder.Record(event, jsonStr); err != nil {
+			mux.logf("[warn] gravita: failed to record query_id=%d request_id=%s: %v", event.QueryID, event.RequestID, err)
+		}
+	}
+	return jsonStr, nil
+}
+
+// ExecuteUDF dispatches args with the metadata in ctx, so that Mux satisfies LambdaUDFHandler and can be used as a handler of another Mux.
+// DeadLetterSink and Recorder are not used, they are the responsibility of the Mux that receives the LambdaUDFEvent.
+func (mux *Mux) ExecuteUDF(ctx context.Context, args [][]interface{}) ([]interface{}, error) {
+	event := &LambdaUDFEvent{
+		LambdaUDFEventMetadata: *Metadata(ctx),
+		Arguments:              args,
+	}
+	event.NumRecords = len(args)
+	output, err := mux.dispatch(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	if !output.Success {
+		return nil, errors.New(output.ErrorMsg)
+	}
+	return output.Results, nil
+}
+
+// dispatch routes the event to an Entry and executes the handler
+func (mux *Mux) dispatch(ctx context.Context, event *LambdaUDFEvent) (*lambdaUDFOutputData, error) {
+	if mux.TraceRouting {
+		mux.logf("[debug] gravita: %s", mux.Explain(event))
+	}
+	entry, handler := mux.lookup(event)
+	var warning string
+	if entry != nil {
+		w, err := mux.guard(entry, event)
+		if err != nil {
+			handler = errorHandler(err)
+		}
+		warning = w
+	}
+	handler = mux.wrap(handler)
+	startAt := time.Now()
+	if entry != nil && entry.audit != nil {
+		defer mux.auditPanic(ctx, *entry.audit, event, startAt, warning)
+	}
+	output := mux.execute(ctx, handler, event)
+	if entry != nil && entry.debug != nil {
+		mux.captureDebug(entry.debug, event, output)
+	}
+	if entry != nil && entry.audit != nil {
+		if err := mux.writeAuditRecord(ctx, *entry.audit, event, output, startAt, warning); err != nil {
+			return nil, err
+		}
+	}
+	return output, nil
+}
+
+// guard checks the invocation of the Entry before executing the handler, and returns the warning of deprecation.
+// The checks are in the order of Switches, the sunset of deprecation and the signature, and the first failure is returned.
+func (mux *Mux) guard(entry *Entry, event *LambdaUDFEvent) (string, error) {
+	if switches := mux.switches(); switches != nil {
+		if err := switches.check(entry, event.ExternalFunction); err != nil {
+			return "", err
 		}
 	}
-	if handler == nil {
-		if mux.NotMatchHandler != nil {
-			handler = mux.NotMatchHandler
-		} else {
-			handler = LambdaUDFHandlerFunc(func(_ context.Context, _ [][]interface{}) ([]interface{}, error) {
-				return nil, fmt.Errorf("external function `%s` not match", event.ExternalFunction)
-			})
+	var warning string
+	if entry.deprecation != nil {
+		w, err := mux.checkDeprecation(entry.deprecation, event)
+		if err != nil {
+			return "", err
+		}
+		warning = w
+	}
+	if entry.signature != nil {
+		if err := entry.signature.Validate(event.Arguments); err != nil {
+			return warning, fmt.Errorf("external function `%s`: %w", event.ExternalFunction, err)
+		}
+	}
+	return warning, nil
+}
+
+func (mux *Mux) lookup(event *LambdaUDFEvent) (*Entry, LambdaUDFHandler) {
+	for _, e := range mux.routes().candidates(event.ExternalFunction) {
+		if e.matchExceptExternalFunction(event) {
+			if e.handler != nil {
+				return e, e.wrap(e.handler)
+			}
 		}
 	}
+	if mux.NotMatchHandler != nil {
+		return nil, mux.NotMatchHandler
+	}
+	return nil, errorHandler(fmt.Errorf("external function `%s` not match", event.ExternalFunction))
+}
+
+func errorHandler(err error) LambdaUDFHandler {
+	return LambdaUDFHandlerFunc(func(_ context.Context, _ [][]interface{}) ([]interface{}, error) {
+		return nil, err
+	})
+}
 
+func (mux *Mux) execute(ctx context.Context, handler LambdaUDFHandler, event *LambdaUDFEvent) *lambdaUDFOutputData {
 	var output lambdaUDFOutputData
-	ctxWithMetadata := withMetadata(ctx, &event.LambdaUDFEventMetadata)
+	ctxWithMetadata := WithMetadata(ctx, &event.LambdaUDFEventMetadata)
 	results, err := handler.ExecuteUDF(ctxWithMetadata, event.Arguments)
 	if err != nil {
 		output.Success = false
 		output.ErrorMsg = err.Error()
+		return &output
+	}
+	n := len(results)
+	if n == event.NumRecords {
+		output.NumRecords = n
+		output.Results = results
+	} else if n < event.NumRecords {
+		output.NumRecords = event.NumRecords
+		output.Results = make([]interface{}, event.NumRecords)
+		copy(output.Results[:n], results[:n])
 	} else {
-		n := len(results)
-		if n == event.NumRecords {
-			output.NumRecords = n
-			output.Results = results
-		} else if n < event.NumRecords {
-			output.NumRecords = event.NumRecords
-			output.Results = make([]interface{}, event.NumRecords)
-			copy(output.Results[:n], results[:n])
-		} else {
-			output.NumRecords = event.NumRecords
-			output.Results = results[:event.NumRecords]
-		}
-		output.Success = true
+		output.NumRecords = event.NumRecords
+		output.Results = results[:event.NumRecords]
 	}
-	var bs []byte
-	bs, err = json.Marshal(output)
-	jsonStr = string(bs)
-	return
+	output.Success = true
+	return &output
 }
 
+// NewEntry registers a new empty Entry to the Mux, to be configured at startup.
+// Each method call on the Entry publishes the modified Entry to the Mux, so that invocations may see the Entry partially configured.
+// To add an Entry while handling events, configure an Entry returned by the package-level NewEntry and register it by AddEntry or Replace.
 func (mux *Mux) NewEntry() *Entry {
-	entry := &Entry{}
-	mux.entries = append(mux.entries, entry)
-	return entry
+	return mux.AddEntry(NewEntry())
 }
 
 func (mux *Mux) Handle(exFunc string, handler LambdaUDFHandler) *Entry {
-	return mux.NewEntry().ExternalFunction(exFunc).Handler(handler)
+	return mux.AddEntry(NewEntry().ExternalFunction(exFunc).Handler(handler))
 }
 
 func (mux *Mux) HandleFunc(exFunc string, f func(context.Context, [][]interface{}) ([]interface{}, error)) *Entry {
-	return mux.NewEntry().ExternalFunction(exFunc).HandlerFunc(f)
+	return mux.AddEntry(NewEntry().ExternalFunction(exFunc).HandlerFunc(f))
 }
 
 func (mux *Mux) HandleRow(exFunc string, handler LambdaUDFRowHandler) *Entry {
-	return mux.NewEntry().ExternalFunction(exFunc).Handler(ParallelRowProcessHandler{
+	return mux.AddEntry(NewEntry().ExternalFunction(exFunc).Handler(ParallelRowProcessHandler{
 		RowHandler: handler,
-	})
+	}))
 }
 
 func (mux *Mux) HandleRowFunc(exFunc string, f func(context.Context, []interface{}) (interface{}, error)) *Entry {
-	return mux.NewEntry().ExternalFunction(exFunc).Handler(ParallelRowProcessHandler{
+	return mux.AddEntry(NewEntry().ExternalFunction(exFunc).Handler(ParallelRowProcessHandler{
 		RowHandler: LambdaUDFRowHandlerFunc(f),
-	})
+	}))
 }
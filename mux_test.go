@@ -113,7 +113,7 @@ func TestMuxHandler(t *testing.T) {
 				mux.HandleFunc("*_udf", func(ctx context.Context, args [][]interface{}) ([]interface{}, error) {
 					ret := make([]interface{}, 0, len(args))
 					return ret, nil
-				}).ClusterRegexp("d.*").Database("d.*").User("t.*")
+				}).ClusterRegexp("d.*").DatabaseRegexp("d.*").UserRegexp("t.*")
 			},
 			expected: `{"results":[null, null, null],"num_records":3, "success": true}`,
 		},
@@ -150,6 +150,133 @@ func TestMuxHandler(t *testing.T) {
 			},
 			expected: `{"error_msg":"not match", "success": false}`,
 		},
+		{
+			casename: "overload by num arguments",
+			callFunc: "concat",
+			prepare: func(mux *gravita.Mux) {
+				mux.HandleRowFunc("concat", func(_ context.Context, args []interface{}) (interface{}, error) {
+					panic(errors.New("matched"))
+				}).NumArguments(1)
+				mux.HandleRowFunc("concat", func(_ context.Context, args []interface{}) (interface{}, error) {
+					return fmt.Sprint(args...), nil
+				}).NumArguments(2)
+			},
+			expected: `{"results":["hoge1", "fuga2", "piyo3" ],"num_records":3, "success": true}`,
+		},
+		{
+			casename: "overload by argument types",
+			callFunc: "concat",
+			callArgs: [][]interface{}{
+				{"hoge", 1},
+				{nil, 2},
+				{"piyo", nil},
+			},
+			prepare: func(mux *gravita.Mux) {
+				mux.HandleRowFunc("concat", func(_ context.Context, args []interface{}) (interface{}, error) {
+					panic(errors.New("matched"))
+				}).ArgumentTypes(gravita.JSONString, gravita.JSONString)
+				mux.HandleRowFunc("concat", func(_ context.Context, args []interface{}) (interface{}, error) {
+					return fmt.Sprint(args...), nil
+				}).ArgumentTypes(gravita.JSONString, gravita.JSONNumber)
+			},
+			expected: `{"results":["hoge1", "<nil> 2", "piyo<nil>" ],"num_records":3, "success": true}`,
+		},
+		{
+			casename: "or matcher",
+			prepare: func(mux *gravita.Mux) {
+				mux.HandleFunc("*", func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+					return make([]interface{}, 0, len(args)), nil
+				}).Matcher(gravita.Or(gravita.ClusterMatcher("hoge"), gravita.ClusterMatcher("dum*")))
+			},
+			expected: `{"results":[null, null, null],"num_records":3, "success": true}`,
+		},
+		{
+			casename: "not matcher",
+			prepare: func(mux *gravita.Mux) {
+				mux.HandleFunc("*", func(ctx context.Context, i [][]interface{}) ([]interface{}, error) {
+					panic(errors.New("matched"))
+				}).Matcher(gravita.Not(gravita.UserMatcher("test")))
+			},
+			expected: `{"error_msg":"external function ` + "`test_udf`" + ` not match", "success": false}`,
+		},
+		{
+			casename: "matcher func",
+			prepare: func(mux *gravita.Mux) {
+				mux.HandleFunc("*", func(ctx context.Context, i [][]interface{}) ([]interface{}, error) {
+					panic(errors.New("matched"))
+				}).Matcher(gravita.And(
+					gravita.DatabaseMatcher("dev"),
+					gravita.MatcherFunc(func(event *gravita.LambdaUDFEvent) bool {
+						return event.QueryID > 100
+					}),
+				))
+			},
+			expected: `{"error_msg":"external function ` + "`test_udf`" + ` not match", "success": false}`,
+		},
+		{
+			casename: "num records and query id",
+			prepare: func(mux *gravita.Mux) {
+				mux.HandleFunc("*", func(ctx context.Context, i [][]interface{}) ([]interface{}, error) {
+					panic(errors.New("matched"))
+				}).NumRecordsAtLeast(4)
+				mux.HandleFunc("*", func(ctx context.Context, i [][]interface{}) ([]interface{}, error) {
+					panic(errors.New("matched"))
+				}).QueryIDRange(11, 20)
+				mux.HandleFunc("*", func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+					return []interface{}{"small"}, nil
+				}).NumRecordsAtMost(3).QueryIDRange(1, 10).RequestID("00000000-*")
+			},
+			expected: `{"results":["small", null, null],"num_records":3, "success": true}`,
+		},
+		{
+			casename: "argument value",
+			prepare: func(mux *gravita.Mux) {
+				mux.HandleFunc("*", func(ctx context.Context, i [][]interface{}) ([]interface{}, error) {
+					panic(errors.New("matched"))
+				}).ArgumentHasPrefix(0, "h")
+				mux.HandleFunc("*", func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+					return []interface{}{"positive"}, nil
+				}).ArgumentValue(1, func(v interface{}) bool {
+					n, ok := v.(int)
+					return ok && n > 0
+				})
+			},
+			expected: `{"results":["positive", null, null],"num_records":3, "success": true}`,
+		},
+		{
+			casename: "priority",
+			prepare: func(mux *gravita.Mux) {
+				mux.HandleFunc("*", func(ctx context.Context, i [][]interface{}) ([]interface{}, error) {
+					panic(errors.New("matched"))
+				})
+				mux.HandleFunc("test_*", func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+					return []interface{}{"high"}, nil
+				}).Priority(10)
+			},
+			expected: `{"results":["high", null, null],"num_records":3, "success": true}`,
+		},
+		{
+			casename: "most specific match",
+			prepare: func(mux *gravita.Mux) {
+				mux.MostSpecificMatch = true
+				mux.HandleFunc("*", func(ctx context.Context, i [][]interface{}) ([]interface{}, error) {
+					panic(errors.New("matched"))
+				})
+				mux.NewEntry().ExternalFunctionRegexp("^test").HandlerFunc(func(ctx context.Context, i [][]interface{}) ([]interface{}, error) {
+					panic(errors.New("matched"))
+				})
+				mux.HandleFunc("test_*", func(ctx context.Context, i [][]interface{}) ([]interface{}, error) {
+					panic(errors.New("matched"))
+				})
+				mux.HandleFunc("test_udf", func(ctx context.Context, i [][]interface{}) ([]interface{}, error) {
+					panic(errors.New("matched"))
+				})
+				mux.HandleFunc("test_udf", func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+					return []interface{}{"exact with cluster"}, nil
+				}).Cluster("dummy")
+			},
+			expected: `{"results":["exact with cluster", null, null],"num_records":3, "success": true}`,
+		},
 		{
 			casename: "row handler",
 			callFunc: "concat",
@@ -0,0 +1,85 @@
+package gravita
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// ExternalFunctionName is a parsed external function name
+type ExternalFunctionName struct {
+	// Schema is empty if the name is not schema-qualified
+	Schema   string
+	Function string
+}
+
+// ParseExternalFunction parses an external function name such as `f_mask`, `analytics.f_mask` or `"Analytics"."f_mask"`.
+// Quoted identifiers are unquoted. If the name is qualified by a database, it is ignored.
+func ParseExternalFunction(name string) ExternalFunctionName {
+	parts := splitIdentifiers(name)
+	switch len(parts) {
+	case 0:
+		return ExternalFunctionName{}
+	case 1:
+		return ExternalFunctionName{Function: parts[0]}
+	}
+	return ExternalFunctionName{
+		Schema:   parts[len(parts)-2],
+		Function: parts[len(parts)-1],
+	}
+}
+
+// String returns `schema.function`, or `function` if the name is not schema-qualified
+func (n ExternalFunctionName) String() string {
+	if n.Schema == "" {
+		return n.Function
+	}
+	return n.Schema + "." + n.Function
+}
+
+func splitIdentifiers(name string) []string {
+	parts := make([]string, 0, 2)
+	var b strings.Builder
+	quoted := false
+	runes := []rune(name)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '"' && quoted && i+1 < len(runes) && runes[i+1] == '"':
+			b.WriteRune('"')
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == '.' && !quoted:
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteRune(c)
+		}
+	}
+	if b.Len() > 0 || len(parts) > 0 {
+		parts = append(parts, b.String())
+	}
+	return parts
+}
+
+// CaseInsensitive sets whether external function names are matched case-insensitively by the external function matchers of all entries,
+// regardless of whether the entries are registered before or after this call. A child Mux created by Group also matches case-insensitively if the parent does.
+// Redshift folds unquoted identifiers to lowercase, so that this is useful when patterns are written in mixed case.
+func (mux *Mux) CaseInsensitive(enable bool) *Mux {
+	var v int32
+	if enable {
+		v = 1
+	}
+	atomic.StoreInt32(&mux.caseInsensitive, v)
+	return mux
+}
+
+// isCaseInsensitive reports whether external function names are matched case-insensitively
+func (mux *Mux) isCaseInsensitive() bool {
+	for m := mux; m != nil; m = m.parent {
+		if atomic.LoadInt32(&m.caseInsensitive) != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package gravita
+
+import "sort"
+
+// Priority sets the priority of the Entry. Entries with higher priority are evaluated first,
+// and entries with the same priority are evaluated in registration order. The default is 0.
+func (e *Entry) Priority(n int) *Entry {
+	return e.update(func(s *entryState) {
+		s.priority = n
+	})
+}
+
+// GetPriority returns the priority of the Entry
+func (e *Entry) GetPriority() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.priority
+}
+
+// Specificity of the external function matcher of an Entry, used when Mux.MostSpecificMatch is enabled
+const (
+	specificityMatchAll = iota
+	specificityRegexp
+	specificityGlob
+	specificityExact
+)
+
+// specificity returns how specific the external function matcher of the Entry is, and the number of other matchers
+func (e *Entry) specificity() (int, int) {
+	spec := specificityMatchAll
+	others := 0
+	for _, m := range e.matchers {
+		s := specificityMatchAll
+		switch m := m.(type) {
+		case matchAllMacher:
+			continue
+		case *externalFunctionMatcher:
+			s = specificityGlob
+			if m.glob == nil {
+				s = specificityExact
+			}
+		case *externalFunctionRegexpMatcher:
+			s = specificityRegexp
+		default:
+			others++
+			continue
+		}
+		if s > spec {
+			spec = s
+		}
+	}
+	return spec, others
+}
+
+// routeOrder returns the indexes of entries in evaluation order
+func routeOrder(entries []*Entry, mostSpecific bool) []int {
+	order := make([]int, len(entries))
+	sorted := true
+	for i, e := range entries {
+		order[i] = i
+		if e.priority != 0 {
+			sorted = false
+		}
+	}
+	if sorted && !mostSpecific {
+		return order
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := entries[order[i]], entries[order[j]]
+		if a.priority != b.priority {
+			return a.priority > b.priority
+		}
+		if !mostSpecific {
+			return false
+		}
+		aSpec, aOthers := a.specificity()
+		bSpec, bOthers := b.specificity()
+		if aSpec != bSpec {
+			return aSpec > bSpec
+		}
+		return aOthers > bOthers
+	})
+	return order
+}
@@ -0,0 +1,220 @@
+package gravita
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recording is a pair of a LambdaUDFEvent and the output returned for it
+type Recording struct {
+	Time   time.Time       `json:"time"`
+	Event  *LambdaUDFEvent `json:"event"`
+	Output json.RawMessage `json:"output"`
+}
+
+// Recorder writes Recordings as JSON lines
+type Recorder struct {
+	mu         sync.Mutex
+	w          io.Writer
+	closer     io.Closer
+	redactions map[string]Redactions
+}
+
+// NewRecorder returns a Recorder that writes to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		w:          w,
+		redactions: make(map[string]Redactions),
+	}
+}
+
+// NewFileRecorder returns a Recorder that appends to the file at path
+func NewFileRecorder(path string) (*Recorder, error) {
+	fp, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	r := NewRecorder(fp)
+	r.closer = fp
+	return r, nil
+}
+
+// Redact registers redactions applied to the arguments of the external function before recording.
+// exFunc "*" applies to external functions that have no redactions of their own.
+func (r *Recorder) Redact(exFunc string, redactions ...ColumnRedaction) *Recorder {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.redactions[exFunc] = append(r.redactions[exFunc], redactions...)
+	return r
+}
+
+// Record writes a Recording of the event and its output
+func (r *Recorder) Record(event *LambdaUDFEvent, output string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	redactions, ok := r.redactions[event.ExternalFunction]
+	if !ok {
+		redactions = r.redactions["*"]
+	}
+	recorded := *event
+	if len(redactions) > 0 {
+		recorded.Arguments = redactions.Apply(event.Arguments)
+	}
+	bs, err := json.Marshal(&Recording{
+		Time:   time.Now(),
+		Event:  &recorded,
+		Output: json.RawMessage(output),
+	})
+	if err != nil {
+		return err
+	}
+	bs = append(bs, '\n')
+	_, err = r.w.Write(bs)
+	return err
+}
+
+// Close closes the underlying file, if the Recorder was created by NewFileRecorder
+func (r *Recorder) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// ReadRecordings reads Recordings written by Recorder
+func ReadRecordings(r io.Reader) ([]*Recording, error) {
+	recordings := make([]*Recording, 0)
+	reader := bufio.NewReader(r)
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			var recording Recording
+			if err := json.Unmarshal(line, &recording); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			recordings = append(recordings, &recording)
+		}
+		if err == io.EOF {
+			return recordings, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// RowDiff represents a mismatched result row
+type RowDiff struct {
+	Row      int
+	Expected interface{}
+	Actual   interface{}
+}
+
+// RecordingDiff represents the difference between a recorded output and a replayed output
+type RecordingDiff struct {
+	Recording *Recording
+	Output    string
+	Messages  []string
+	Rows      []RowDiff
+}
+
+// ReplayReport is the result of ReplayRecordings
+type ReplayReport struct {
+	Total   int
+	Matched int
+	Diffs   []*RecordingDiff
+}
+
+// OK reports whether all replayed outputs matched the recorded ones
+func (r *ReplayReport) OK() bool {
+	return len(r.Diffs) == 0
+}
+
+// String returns a human readable diff report
+func (r *ReplayReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d/%d recordings matched\n", r.Matched, r.Total)
+	for _, d := range r.Diffs {
+		event := d.Recording.Event
+		fmt.Fprintf(&b, "--- external_function=%s query_id=%d request_id=%s\n", event.ExternalFunction, event.QueryID, event.RequestID)
+		for _, msg := range d.Messages {
+			fmt.Fprintf(&b, "  %s\n", msg)
+		}
+		for _, row := range d.Rows {
+			expected, _ := json.Marshal(row.Expected)
+			actual, _ := json.Marshal(row.Actual)
+			fmt.Fprintf(&b, "  row %d:\n    - %s\n    + %s\n", row.Row, expected, actual)
+		}
+	}
+	return b.String()
+}
+
+// ReplayRecordings replays the recorded events through mux and compares the outputs with the recorded ones
+func ReplayRecordings(ctx context.Context, mux *Mux, recordings []*Recording) *ReplayReport {
+	report := &ReplayReport{
+		Total: len(recordings),
+	}
+	for _, recording := range recordings {
+		diff := &RecordingDiff{
+			Recording: recording,
+		}
+		output, err := mux.HandleLambdaEvent(ctx, recording.Event)
+		if err != nil {
+			diff.Messages = append(diff.Messages, fmt.Sprintf("invocation error: %v", err))
+		} else {
+			diff.Output = output
+			compareOutputs(diff, recording.Output, output)
+		}
+		if len(diff.Messages) == 0 && len(diff.Rows) == 0 {
+			report.Matched++
+			continue
+		}
+		report.Diffs = append(report.Diffs, diff)
+	}
+	return report
+}
+
+func compareOutputs(diff *RecordingDiff, recorded json.RawMessage, replayed string) {
+	var expected, actual lambdaUDFOutputData
+	if err := json.Unmarshal(recorded, &expected); err != nil {
+		diff.Messages = append(diff.Messages, fmt.Sprintf("invalid recorded output: %v", err))
+		return
+	}
+	if err := json.Unmarshal([]byte(replayed), &actual); err != nil {
+		diff.Messages = append(diff.Messages, fmt.Sprintf("invalid replayed output: %v", err))
+		return
+	}
+	if expected.Success != actual.Success {
+		diff.Messages = append(diff.Messages, fmt.Sprintf("success: %v => %v", expected.Success, actual.Success))
+	}
+	if expected.ErrorMsg != actual.ErrorMsg {
+		diff.Messages = append(diff.Messages, fmt.Sprintf("error_msg: %q => %q", expected.ErrorMsg, actual.ErrorMsg))
+	}
+	if expected.NumRecords != actual.NumRecords {
+		diff.Messages = append(diff.Messages, fmt.Sprintf("num_records: %d => %d", expected.NumRecords, actual.NumRecords))
+	}
+	n := len(expected.Results)
+	if len(actual.Results) > n {
+		n = len(actual.Results)
+	}
+	for i := 0; i < n; i++ {
+		var e, a interface{}
+		if i < len(expected.Results) {
+			e = expected.Results[i]
+		}
+		if i < len(actual.Results) {
+			a = actual.Results[i]
+		}
+		if !reflect.DeepEqual(e, a) {
+			diff.Rows = append(diff.Rows, RowDiff{Row: i, Expected: e, Actual: a})
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package gravita_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := gravita.NewRecorder(&buf).Redact("secret_udf", gravita.RedactColumn(0, gravita.RedactMask))
+	mux := gravita.NewMux()
+	mux.Recorder = recorder
+	mux.HandleRowFunc("*", func(_ context.Context, args []interface{}) (interface{}, error) {
+		return fmt.Sprint(args...), nil
+	})
+	_, err := mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("concat", [][]interface{}{{"hoge", 1}, {"fuga", 2}}))
+	require.NoError(t, err)
+	_, err = mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("secret_udf", [][]interface{}{{"abc"}}))
+	require.NoError(t, err)
+
+	recordings, err := gravita.ReadRecordings(&buf)
+	require.NoError(t, err)
+	require.Len(t, recordings, 2)
+	require.Equal(t, [][]interface{}{{"***"}}, recordings[1].Event.Arguments)
+
+	report := gravita.ReplayRecordings(context.Background(), mux, recordings[:1])
+	require.True(t, report.OK(), report.String())
+
+	updated := gravita.NewMux()
+	updated.HandleRowFunc("*", func(_ context.Context, args []interface{}) (interface{}, error) {
+		if args[0] == "fuga" {
+			return "changed", nil
+		}
+		return fmt.Sprint(args...), nil
+	})
+	report = gravita.ReplayRecordings(context.Background(), updated, recordings[:1])
+	require.False(t, report.OK())
+	require.Len(t, report.Diffs, 1)
+	require.Equal(t, []gravita.RowDiff{{Row: 1, Expected: "fuga2", Actual: "changed"}}, report.Diffs[0].Rows)
+	require.True(t, strings.HasPrefix(report.String(), "0/1 recordings matched\n"))
+}
@@ -0,0 +1,123 @@
+package gravita
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type redactionKind int
+
+const (
+	redactionDrop redactionKind = iota + 1
+	redactionHash
+	redactionMask
+	redactionTruncate
+)
+
+// RedactionRule represents how to redact an argument value
+type RedactionRule struct {
+	kind   redactionKind
+	length int
+	key    []byte
+}
+
+var (
+	// RedactDrop removes the column
+	RedactDrop = RedactionRule{kind: redactionDrop}
+	// RedactHash replaces the value with its SHA-256 hash.
+	// A plain hash of low-entropy values such as emails is reversed by a dictionary lookup, use RedactHMAC for personal data.
+	RedactHash = RedactionRule{kind: redactionHash}
+	// RedactMask replaces each character of string values with '*', other values with RedactedValue
+	RedactMask = RedactionRule{kind: redactionMask}
+)
+
+// RedactHMAC replaces the value with its HMAC-SHA256 keyed by key
+func RedactHMAC(key []byte) RedactionRule {
+	return RedactionRule{kind: redactionHash, key: key}
+}
+
+// RedactTruncate keeps only the first n characters of string values. Negative n is treated as 0
+func RedactTruncate(n int) RedactionRule {
+	if n < 0 {
+		n = 0
+	}
+	return RedactionRule{kind: redactionTruncate, length: n}
+}
+
+func (r RedactionRule) apply(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	switch r.kind {
+	case redactionHash:
+		return hashValue(r.key, v)
+	case redactionMask:
+		if str, ok := v.(string); ok {
+			return strings.Repeat("*", utf8.RuneCountInString(str))
+		}
+		return RedactedValue
+	case redactionTruncate:
+		if str, ok := v.(string); ok {
+			if utf8.RuneCountInString(str) > r.length {
+				return string([]rune(str)[:r.length])
+			}
+		}
+		return v
+	}
+	return v
+}
+
+// ColumnRedaction is a RedactionRule for a column (0-origin) of arguments
+type ColumnRedaction struct {
+	Column int
+	Rule   RedactionRule
+}
+
+// RedactColumn returns a ColumnRedaction
+func RedactColumn(column int, rule RedactionRule) ColumnRedaction {
+	return ColumnRedaction{
+		Column: column,
+		Rule:   rule,
+	}
+}
+
+// Redactions is a set of ColumnRedaction. columns without rule are kept as is
+type Redactions []ColumnRedaction
+
+// Apply returns a redacted copy of args
+func (rs Redactions) Apply(args [][]interface{}) [][]interface{} {
+	ret := make([][]interface{}, len(args))
+	for i, rowArgs := range args {
+		ret[i] = rs.ApplyRow(rowArgs)
+	}
+	return ret
+}
+
+// ApplyRow returns a redacted copy of a row of arguments
+func (rs Redactions) ApplyRow(rowArgs []interface{}) []interface{} {
+	row := make([]interface{}, len(rowArgs))
+	copy(row, rowArgs)
+	drops := make([]int, 0)
+	for _, r := range rs {
+		if r.Column < 0 || r.Column >= len(row) {
+			continue
+		}
+		if r.Rule.kind == redactionDrop {
+			drops = append(drops, r.Column)
+			continue
+		}
+		row[r.Column] = r.Rule.apply(row[r.Column])
+	}
+	if len(drops) == 0 {
+		return row
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(drops)))
+	for i, column := range drops {
+		if i > 0 && drops[i-1] == column {
+			continue
+		}
+		row = append(row[:column], row[column+1:]...)
+	}
+	return row
+}
@@ -0,0 +1,129 @@
+package gravita
+
+// NewEntry returns an Entry that is not registered to any Mux.
+// Configure it and register it by Mux.AddEntry or Mux.Replace
+func NewEntry() *Entry {
+	return &Entry{}
+}
+
+// Clone returns a copy of the Entry that is not registered to any Mux
+func (e *Entry) Clone() *Entry {
+	return &Entry{entryState: e.state()}
+}
+
+// freeze returns the copy of the Entry published to the Mux. e.mu must be held
+func (e *Entry) freeze() *Entry {
+	return &Entry{
+		entryState: e.entryState.clone(),
+		origin:     e,
+	}
+}
+
+// snapshot returns the current entries. The returned slice and entries must not be modified
+func (mux *Mux) snapshot() []*Entry {
+	fold := mux.isCaseInsensitive()
+	mux.mu.Lock()
+	entries := mux.entries
+	mux.mu.Unlock()
+	return foldEntries(entries, fold)
+}
+
+// foldEntries returns the entries as they are matched, that is, folded if Mux.CaseInsensitive is enabled
+func foldEntries(entries []*Entry, fold bool) []*Entry {
+	if !fold {
+		return entries
+	}
+	folded := make([]*Entry, len(entries))
+	for i, e := range entries {
+		folded[i] = e.foldCase()
+	}
+	return folded
+}
+
+// AddEntry registers the Entry to the Mux. It is safe to call while handling events.
+// An Entry is registered to one Mux, use Clone to register the same Entry to another Mux.
+func (mux *Mux) AddEntry(entry *Entry) *Entry {
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	entry.mux = mux
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	entries := make([]*Entry, len(mux.entries), len(mux.entries)+1)
+	copy(entries, mux.entries)
+	mux.entries = append(entries, entry.freeze())
+	mux.invalidateLocked()
+	return entry
+}
+
+// republish replaces the copies of the Entry registered to the Mux. e.mu must be held
+func (mux *Mux) republish(e *Entry) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	var entries []*Entry
+	for i, p := range mux.entries {
+		if p.origin != e {
+			continue
+		}
+		if entries == nil {
+			entries = make([]*Entry, len(mux.entries))
+			copy(entries, mux.entries)
+		}
+		entries[i] = e.freeze()
+	}
+	if entries == nil {
+		return
+	}
+	mux.entries = entries
+	mux.invalidateLocked()
+}
+
+// Remove unregisters the entries with the name, and reports whether any Entry is removed.
+// It is safe to call while handling events.
+func (mux *Mux) Remove(name string) bool {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	entries := make([]*Entry, 0, len(mux.entries))
+	for _, e := range mux.entries {
+		if e.name == name {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == len(mux.entries) {
+		return false
+	}
+	mux.entries = entries
+	mux.invalidateLocked()
+	return true
+}
+
+// Replace replaces the entries with the name by the Entry at the position of the first one,
+// and reports whether any Entry is replaced. If there is no Entry with the name, the Entry is added to the last.
+// The name of the Entry is set to name. It is safe to call while handling events.
+func (mux *Mux) Replace(name string, entry *Entry) bool {
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	entry.name = name
+	entry.mux = mux
+	published := entry.freeze()
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	entries := make([]*Entry, 0, len(mux.entries)+1)
+	replaced := false
+	for _, e := range mux.entries {
+		if e.name != name && e.origin != entry {
+			entries = append(entries, e)
+			continue
+		}
+		if !replaced {
+			entries = append(entries, published)
+			replaced = true
+		}
+	}
+	if !replaced {
+		entries = append(entries, published)
+	}
+	mux.entries = entries
+	mux.invalidateLocked()
+	return replaced
+}
@@ -0,0 +1,132 @@
+package gravita_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuxRemoveAndReplace(t *testing.T) {
+	handler := func(result string) func(context.Context, []interface{}) (interface{}, error) {
+		return func(_ context.Context, _ []interface{}) (interface{}, error) {
+			return result, nil
+		}
+	}
+	mux := gravita.NewMux()
+	mux.HandleRowFunc("test_udf", handler("v1")).Name("test")
+	mux.HandleRowFunc("*", handler("fallback")).Name("fallback")
+	event := testLambdaUDFEvent("test_udf", [][]interface{}{{1}})
+
+	actual, err := mux.HandleLambdaEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"success":true,"num_records":1,"results":["v1"]}`, actual)
+
+	entry := gravita.NewEntry().ExternalFunction("test_udf").HandlerFunc(func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+		return []interface{}{"v2"}, nil
+	})
+	require.True(t, mux.Replace("test", entry))
+	actual, err = mux.HandleLambdaEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"success":true,"num_records":1,"results":["v2"]}`, actual)
+	require.Equal(t, []string{"test", "fallback"}, entryNames(mux))
+
+	require.True(t, mux.Remove("test"))
+	require.False(t, mux.Remove("test"))
+	actual, err = mux.HandleLambdaEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"success":true,"num_records":1,"results":["fallback"]}`, actual)
+
+	require.False(t, mux.Replace("new", gravita.NewEntry().HandlerFunc(nil)))
+	require.Equal(t, []string{"fallback", "new"}, entryNames(mux))
+}
+
+func entryNames(mux *gravita.Mux) []string {
+	names := make([]string, 0)
+	for _, d := range mux.Entries() {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+func TestMuxConcurrentMutation(t *testing.T) {
+	mux := gravita.NewMux()
+	mux.HandleRowFunc("*", func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return "fallback", nil
+	}).Priority(-1)
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			event := testLambdaUDFEvent(fmt.Sprintf("udf_%d", i), [][]interface{}{{1}})
+			for ctx.Err() == nil {
+				if _, err := mux.HandleLambdaEvent(context.Background(), event); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("udf_%d", i%4)
+		entry := gravita.NewEntry().ExternalFunction(name).HandlerFunc(func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+			return make([]interface{}, len(args)), nil
+		})
+		if i%3 == 0 {
+			mux.Remove(name)
+		} else {
+			mux.Replace(name, entry)
+		}
+	}
+	cancel()
+	wg.Wait()
+}
+
+func TestMuxMutateRegisteredEntry(t *testing.T) {
+	mux := gravita.NewMux()
+	mux.HandleRowFunc("*", func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return "fallback", nil
+	}).Priority(-1)
+	entry := mux.HandleRowFunc("udf", func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return "v0", nil
+	})
+	event := testLambdaUDFEvent("udf", [][]interface{}{{1}})
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if _, err := mux.HandleLambdaEvent(context.Background(), event); err != nil {
+					t.Error(err)
+					return
+				}
+				mux.Entries()
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		result := fmt.Sprintf("v%d", i)
+		entry.Priority(i).
+			Cluster("dummy").
+			Handler(gravita.ParallelRowProcessHandler{
+				RowHandler: gravita.LambdaUDFRowHandlerFunc(func(_ context.Context, _ []interface{}) (interface{}, error) {
+					return result, nil
+				}),
+			})
+	}
+	cancel()
+	wg.Wait()
+
+	actual, err := mux.HandleLambdaEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"success":true,"num_records":1,"results":["v99"]}`, actual)
+	require.Equal(t, 99, mux.Entries()[1].Priority)
+	require.Len(t, mux.Entries()[1].Matchers, 101)
+}
@@ -0,0 +1,163 @@
+package gravita
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxRouteCacheSize is the maximum number of external function names whose candidate entries are cached
+const maxRouteCacheSize = 4096
+
+// routeTable is the index of entries for dispatching events.
+// Entries with an exact external function name are resolved through a map, other entries are evaluated as fallback,
+// and the candidate entries are cached per external function name.
+type routeTable struct {
+	mostSpecific bool
+	fold         bool
+	entries      []*Entry
+	exact        map[string][]int
+	foldExact    map[string][]int
+	fallback     []int
+
+	mu    sync.RWMutex
+	cache map[string][]*Entry
+}
+
+func newRouteTable(entries []*Entry, order []int) *routeTable {
+	t := &routeTable{
+		entries:   make([]*Entry, 0, len(order)),
+		exact:     make(map[string][]int),
+		foldExact: make(map[string][]int),
+		cache:     make(map[string][]*Entry),
+	}
+	for rank, i := range order {
+		e := entries[i]
+		t.entries = append(t.entries, e)
+		if m := e.exactExternalFunctionMatcher(); m != nil {
+			if m.fold {
+				key := strings.ToLower(m.pattern)
+				t.foldExact[key] = append(t.foldExact[key], rank)
+			} else {
+				t.exact[m.pattern] = append(t.exact[m.pattern], rank)
+			}
+			continue
+		}
+		t.fallback = append(t.fallback, rank)
+	}
+	return t
+}
+
+// candidates returns the entries whose external function matchers match the name, in evaluation order
+func (t *routeTable) candidates(name string) []*Entry {
+	t.mu.RLock()
+	c, ok := t.cache[name]
+	t.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	ranks := make(map[int]struct{})
+	parsed := ParseExternalFunction(name)
+	keys := []string{name, parsed.String()}
+	if parsed.Schema != "" {
+		keys = append(keys, parsed.Function)
+	}
+	for _, key := range keys {
+		for _, rank := range t.exact[key] {
+			ranks[rank] = struct{}{}
+		}
+		for _, rank := range t.foldExact[strings.ToLower(key)] {
+			ranks[rank] = struct{}{}
+		}
+	}
+	for _, rank := range t.fallback {
+		ranks[rank] = struct{}{}
+	}
+	sorted := make([]int, 0, len(ranks))
+	for rank := range ranks {
+		sorted = append(sorted, rank)
+	}
+	sort.Ints(sorted)
+	c = make([]*Entry, 0, len(sorted))
+	for _, rank := range sorted {
+		if e := t.entries[rank]; e.matchExternalFunction(name) {
+			c = append(c, e)
+		}
+	}
+
+	t.mu.Lock()
+	if len(t.cache) >= maxRouteCacheSize {
+		t.cache = make(map[string][]*Entry)
+	}
+	t.cache[name] = c
+	t.mu.Unlock()
+	return c
+}
+
+// exactExternalFunctionMatcher returns an exact external function matcher of the Entry, or nil
+func (e *Entry) exactExternalFunctionMatcher() *externalFunctionMatcher {
+	for _, m := range e.matchers {
+		if m, ok := m.(*externalFunctionMatcher); ok && m.glob == nil {
+			return m
+		}
+	}
+	return nil
+}
+
+func isExternalFunctionMatcher(m Matcher) bool {
+	switch m.(type) {
+	case *externalFunctionMatcher, *externalFunctionRegexpMatcher:
+		return true
+	}
+	return false
+}
+
+// matchExternalFunction evaluates only the external function matchers of the Entry
+func (e *Entry) matchExternalFunction(name string) bool {
+	event := &LambdaUDFEvent{
+		LambdaUDFEventMetadata: LambdaUDFEventMetadata{
+			ExternalFunction: name,
+		},
+	}
+	for _, m := range e.matchers {
+		if isExternalFunctionMatcher(m) && !m.Match(event) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchExceptExternalFunction evaluates the matchers of the Entry except the external function matchers
+func (e *Entry) matchExceptExternalFunction(event *LambdaUDFEvent) bool {
+	for _, m := range e.matchers {
+		if !isExternalFunctionMatcher(m) && !m.Match(event) {
+			return false
+		}
+	}
+	return true
+}
+
+// routes returns the current routeTable, rebuilding it if entries have been changed
+func (mux *Mux) routes() *routeTable {
+	fold := mux.isCaseInsensitive()
+	if t, ok := mux.table.Load().(*routeTable); ok && t != nil && t.mostSpecific == mux.MostSpecificMatch && t.fold == fold {
+		return t
+	}
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	if t, ok := mux.table.Load().(*routeTable); ok && t != nil && t.mostSpecific == mux.MostSpecificMatch && t.fold == fold {
+		return t
+	}
+	entries := foldEntries(mux.entries, fold)
+	t := newRouteTable(entries, routeOrder(entries, mux.MostSpecificMatch))
+	t.mostSpecific = mux.MostSpecificMatch
+	t.fold = fold
+	mux.table.Store(t)
+	return t
+}
+
+// invalidateLocked notifies the Mux that the routing of entries has been changed. mux.mu must be held
+func (mux *Mux) invalidateLocked() {
+	mux.table.Store((*routeTable)(nil))
+}
@@ -0,0 +1,68 @@
+package gravita_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteCacheInvalidation(t *testing.T) {
+	mux := gravita.NewMux()
+	mux.HandleRowFunc("test_*", func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return "glob", nil
+	})
+	event := testLambdaUDFEvent("test_udf", [][]interface{}{{1}})
+	actual, err := mux.HandleLambdaEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"success":true,"num_records":1,"results":["glob"]}`, actual)
+
+	entry := mux.HandleRowFunc("test_udf", func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return "exact", nil
+	})
+	actual, err = mux.HandleLambdaEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"success":true,"num_records":1,"results":["glob"]}`, actual)
+
+	entry.Priority(1)
+	actual, err = mux.HandleLambdaEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"success":true,"num_records":1,"results":["exact"]}`, actual)
+
+	entry.Cluster("other")
+	actual, err = mux.HandleLambdaEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"success":true,"num_records":1,"results":["glob"]}`, actual)
+}
+
+func BenchmarkMuxHandleLambdaEvent(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		for _, kind := range []string{"exact", "glob"} {
+			b.Run(fmt.Sprintf("%s_%d", kind, n), func(b *testing.B) {
+				mux := gravita.NewMux()
+				for i := 0; i < n; i++ {
+					pattern := fmt.Sprintf("udf_%04d", i)
+					if kind == "glob" {
+						pattern = fmt.Sprintf("udf_%04d_*", i)
+					}
+					mux.HandleRowFunc(pattern, func(_ context.Context, args []interface{}) (interface{}, error) {
+						return args[0], nil
+					})
+				}
+				exFunc := fmt.Sprintf("udf_%04d", n-1)
+				if kind == "glob" {
+					exFunc = fmt.Sprintf("udf_%04d_v1", n-1)
+				}
+				event := testLambdaUDFEvent(exFunc, [][]interface{}{{1}})
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := mux.HandleLambdaEvent(context.Background(), event); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package gravita
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvSettingsPrefix is the prefix of environment variables overriding execution settings of entries
+const EnvSettingsPrefix = "GRAVITA_"
+
+// Execution settings overridable by environment variables named GRAVITA_<KEY>_<SETTING>, see Entry.SettingsKey.
+// For example, GRAVITA_GEOCODE_BATCH_SIZE=100 sets the batch size of the BatchProcessHandler of the entry `geocode`.
+// The key of an Entry in a child Mux is prefixed by the prefix of Mount, or by the name of the group Entry and an underscore:
+// GRAVITA_TEAM_A_GEOCODE_BATCH_SIZE for `geocode` mounted by Mount("team_a_", child),
+// and GRAVITA_GROUP0_GEOCODE_BATCH_SIZE for `geocode` in the child created by the unnamed 1st Entry by Group.
+const (
+	SettingBatchSize      = "BATCH_SIZE"
+	SettingMaxBatchCount  = "MAX_BATCH_COUNT"
+	SettingDistinct       = "DISTINCT"
+	SettingMaxConcurrency = "MAX_CONCURRENCY"
+	SettingCanaryPercent  = "CANARY_PERCENT"
+)
+
+var settingNames = []string{SettingBatchSize, SettingMaxBatchCount, SettingDistinct, SettingMaxConcurrency, SettingCanaryPercent}
+
+// SettingsKey returns the key of environment variables overriding the execution settings of the Entry.
+// It is the name of the Entry, or the exact external function name if unnamed, upper-cased with non-alphanumerics replaced by underscores.
+// It returns an empty string if the Entry has neither. The key of an Entry in a child Mux is prefixed, see ApplyEnvSettings.
+func (e *Entry) SettingsKey() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.settingsKey("")
+}
+
+// settingsKey returns the SettingsKey prefixed by the path of the child Mux
+func (e *Entry) settingsKey(prefix string) string {
+	key := e.name
+	if key == "" {
+		key = e.functionName()
+	}
+	if key == "" {
+		return ""
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, prefix+key)
+}
+
+// settingsPrefix returns the prefix of the keys of the entries in the child Mux that the i-th Entry delegates to:
+// the prefix of Mount, or the name of the Entry created by Group followed by an underscore, which is `GROUP<i>` if the Entry is unnamed.
+func (e *Entry) settingsPrefix(i int) string {
+	if h, ok := e.handler.(*mountHandler); ok {
+		return h.prefix
+	}
+	if key := e.settingsKey(""); key != "" {
+		return key + "_"
+	}
+	return fmt.Sprintf("GROUP%d_", i)
+}
+
+// settingsTarget is an Entry whose settings are overridden by ApplyEnvSettings
+type settingsTarget struct {
+	key   string
+	entry *Entry
+	desc  EntryDescriptor
+}
+
+func (mux *Mux) settingsTargets(path string, prefix string, targets []settingsTarget) []settingsTarget {
+	for i, e := range mux.snapshot() {
+		desc := describeEntry(i, e)
+		desc.Path = path
+		if key := e.settingsKey(prefix); key != "" {
+			targets = append(targets, settingsTarget{key: key, entry: e, desc: desc})
+		}
+		if child, _ := e.delegate(); child != nil {
+			targets = child.settingsTargets(childPath(path, i), prefix+e.settingsPrefix(i), targets)
+		}
+	}
+	return targets
+}
+
+// ApplyEnvSettings overrides the execution settings of the handlers of the entries by environment variables.
+// The handlers are not modified, the entries are updated with copies of the handlers except SplitHandler, whose percentage is changed in place.
+// Supported handlers are BatchProcessHandler (BATCH_SIZE, MAX_BATCH_COUNT, DISTINCT and MAX_CONCURRENCY), ParallelRowProcessHandler (MAX_CONCURRENCY)
+// and SplitHandler (CANARY_PERCENT).
+// It should be called at startup before handling events. All invalid values are reported at once,
+// and variables that match no Entry are logged as warnings.
+func (mux *Mux) ApplyEnvSettings() error {
+	return mux.applySettings(os.Environ())
+}
+
+func (mux *Mux) applySettings(environ []string) error {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		if i := strings.IndexByte(kv, '='); i > 0 && strings.HasPrefix(kv, EnvSettingsPrefix) {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	used := make(map[string]bool)
+	var msgs []string
+	for _, target := range mux.settingsTargets("", "", nil) {
+		key, e := target.key, target.entry
+		handler := e.handler
+		overrides := make(map[string]string)
+		for _, setting := range settingNames {
+			name := EnvSettingsPrefix + key + "_" + setting
+			value, ok := env[name]
+			if !ok {
+				continue
+			}
+			used[name] = true
+			h, err := applySetting(handler, setting, value)
+			if err != nil {
+				msgs = append(msgs, fmt.Sprintf("%s: %s: %v", target.desc, name, err))
+				continue
+			}
+			handler = h
+			overrides[name] = value
+		}
+		if len(overrides) == 0 {
+			continue
+		}
+		e.origin.update(func(s *entryState) {
+			s.handler = handler
+			if s.overrides == nil {
+				s.overrides = make(map[string]string)
+			}
+			for name, value := range overrides {
+				s.overrides[name] = value
+			}
+		})
+	}
+	unused := make([]string, 0)
+	for name := range env {
+		if used[name] {
+			continue
+		}
+		for _, setting := range settingNames {
+			if strings.HasSuffix(name, "_"+setting) {
+				unused = append(unused, name)
+				break
+			}
+		}
+	}
+	sort.Strings(unused)
+	for _, name := range unused {
+		mux.logf("[warn] gravita: %s matches no entry", name)
+	}
+	if len(msgs) > 0 {
+		return fmt.Errorf("invalid settings: %s", strings.Join(msgs, ", "))
+	}
+	return nil
+}
+
+func applySetting(handler LambdaUDFHandler, setting string, value string) (LambdaUDFHandler, error) {
+	switch h := handler.(type) {
+	case *BatchProcessHandler:
+		c := *h
+		h = &c
+		switch setting {
+		case SettingBatchSize:
+			n, err := parseSettingInt(value, 1)
+			if err != nil {
+				return nil, err
+			}
+			h.BatchSize(n)
+		case SettingMaxBatchCount:
+			n, err := parseSettingInt(value, 1)
+			if err != nil {
+				return nil, err
+			}
+			h.MaxBatchCount(n)
+		case SettingDistinct:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("must be a boolean, got %q", value)
+			}
+			h.Distinct(b)
+		case SettingMaxConcurrency:
+			n, err := parseSettingInt(value, 0)
+			if err != nil {
+				return nil, err
+			}
+			h.MaxConcurrency(n)
+		}
+		return h, nil
+	case ParallelRowProcessHandler:
+		if setting == SettingMaxConcurrency {
+			n, err := parseSettingInt(value, 0)
+			if err != nil {
+				return nil, err
+			}
+			h.MaxConcurrency = n
+			return h, nil
+		}
+	case *ParallelRowProcessHandler:
+		if setting == SettingMaxConcurrency {
+			n, err := parseSettingInt(value, 0)
+			if err != nil {
+				return nil, err
+			}
+			c := *h
+			c.MaxConcurrency = n
+			return &c, nil
+		}
+	case *SplitHandler:
+		if setting == SettingCanaryPercent {
+			p, err := strconv.ParseFloat(value, 64)
+			if err != nil || p < 0 || p > 100 {
+				return nil, fmt.Errorf("must be a number from 0 to 100, got %q", value)
+			}
+			h.SetPercent(p)
+			return h, nil
+		}
+	}
+	return nil, fmt.Errorf("handler %T does not support %s", handler, setting)
+}
+
+func parseSettingInt(value string, min int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min {
+		return 0, fmt.Errorf("must be an integer >= %d, got %q", min, value)
+	}
+	return n, nil
+}
+
+// handlerSettings returns the current execution settings of the handler, or nil if the handler has none
+func handlerSettings(handler LambdaUDFHandler) map[string]string {
+	switch h := handler.(type) {
+	case *BatchProcessHandler:
+		settings := map[string]string{
+			SettingBatchSize:      strconv.Itoa(h.GetBatchSize()),
+			SettingDistinct:       strconv.FormatBool(h.GetDistinct()),
+			SettingMaxConcurrency: strconv.Itoa(h.GetMaxConcurrency()),
+		}
+		if n, ok := h.GetMaxBatchCount(); ok {
+			settings[SettingMaxBatchCount] = strconv.Itoa(n)
+		}
+		return settings
+	case ParallelRowProcessHandler:
+		return map[string]string{SettingMaxConcurrency: strconv.Itoa(h.MaxConcurrency)}
+	case *ParallelRowProcessHandler:
+		return map[string]string{SettingMaxConcurrency: strconv.Itoa(h.MaxConcurrency)}
+	case *SplitHandler:
+		return map[string]string{SettingCanaryPercent: strconv.FormatFloat(h.GetPercent(), 'f', -1, 64)}
+	}
+	return nil
+}
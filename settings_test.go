@@ -0,0 +1,119 @@
+package gravita_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuxApplyEnvSettings(t *testing.T) {
+	envs := map[string]string{
+		"GRAVITA_GEO_CODE_BATCH_SIZE":     "2",
+		"GRAVITA_GEO_CODE_DISTINCT":       "true",
+		"GRAVITA_MASK_MAX_CONCURRENCY":    "4",
+		"GRAVITA_UNKNOWN_MAX_BATCH_COUNT": "1",
+	}
+	for k, v := range envs {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	var buf bytes.Buffer
+	mux := gravita.NewMux()
+	mux.Logger = log.New(&buf, "", 0)
+	var batches int
+	batch := gravita.NewBatchProcessHandler(10, gravita.LambdaUDFHandlerFunc(func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+		batches++
+		results := make([]interface{}, len(args))
+		for i := range args {
+			results[i] = args[i][0]
+		}
+		return results, nil
+	}))
+	batch.MaxConcurrency(1)
+	mux.Handle("geo-code", batch)
+	mux.HandleRowFunc("mask_*", func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return "***", nil
+	}).Name("mask")
+
+	require.NoError(t, mux.ApplyEnvSettings())
+	require.Contains(t, buf.String(), "[warn] gravita: GRAVITA_UNKNOWN_MAX_BATCH_COUNT matches no entry")
+	require.Equal(t, 10, batch.GetBatchSize(), "the registered handler is not modified")
+	require.False(t, batch.GetDistinct())
+
+	entries := mux.Entries()
+	require.Equal(t, map[string]string{
+		gravita.SettingBatchSize:      "2",
+		gravita.SettingDistinct:       "true",
+		gravita.SettingMaxConcurrency: "1",
+	}, entries[0].Settings)
+	require.Equal(t, map[string]string{
+		"GRAVITA_GEO_CODE_BATCH_SIZE": "2",
+		"GRAVITA_GEO_CODE_DISTINCT":   "true",
+	}, entries[0].EnvOverrides)
+	require.Equal(t, map[string]string{gravita.SettingMaxConcurrency: "4"}, entries[1].Settings)
+
+	event := testLambdaUDFEvent("geo-code", [][]interface{}{{"a"}, {"b"}, {"a"}, {"c"}})
+	actual, err := mux.HandleLambdaEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"success":true,"num_records":4,"results":["a","b","a","c"]}`, actual)
+	require.Equal(t, 2, batches)
+}
+
+func TestMuxApplyEnvSettingsInvalid(t *testing.T) {
+	envs := map[string]string{
+		"GRAVITA_GEOCODE_BATCH_SIZE": "0",
+		"GRAVITA_GEOCODE_DISTINCT":   "yes",
+		"GRAVITA_MASK_BATCH_SIZE":    "10",
+	}
+	for k, v := range envs {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	mux := gravita.NewMux()
+	mux.Handle("geocode", gravita.NewBatchProcessHandler(10, gravita.LambdaUDFHandlerFunc(nil)))
+	mux.HandleRowFunc("mask", func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return "***", nil
+	})
+	err := mux.ApplyEnvSettings()
+	require.EqualError(t, err, `invalid settings: `+
+		`entry[0]: GRAVITA_GEOCODE_BATCH_SIZE: must be an integer >= 1, got "0", `+
+		`entry[0]: GRAVITA_GEOCODE_DISTINCT: must be a boolean, got "yes", `+
+		`entry[1]: GRAVITA_MASK_BATCH_SIZE: handler gravita.ParallelRowProcessHandler does not support BATCH_SIZE`)
+}
+
+func TestMuxApplyEnvSettingsChildren(t *testing.T) {
+	envs := map[string]string{
+		"GRAVITA_GROUP0_GEOCODE_BATCH_SIZE":   "2",
+		"GRAVITA_TEAM_A_MASK_MAX_CONCURRENCY": "4",
+	}
+	for k, v := range envs {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	var buf bytes.Buffer
+	mux := gravita.NewMux()
+	mux.Logger = log.New(&buf, "", 0)
+	group := mux.Group(gravita.DatabaseMatcher("analytics"))
+	group.Handle("geocode", gravita.NewBatchProcessHandler(10, gravita.LambdaUDFHandlerFunc(func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+		return make([]interface{}, len(args)), nil
+	})))
+	teamA := gravita.NewMux()
+	teamA.HandleRowFunc("mask", func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return "***", nil
+	})
+	mux.Mount("team_a_", teamA)
+
+	require.NoError(t, mux.ApplyEnvSettings())
+	require.Empty(t, buf.String())
+	entries := mux.Entries()
+	require.Equal(t, "entry[0]/entry[0]", entries[1].String())
+	require.Equal(t, "2", entries[1].Settings[gravita.SettingBatchSize])
+	require.Equal(t, map[string]string{"GRAVITA_GROUP0_GEOCODE_BATCH_SIZE": "2"}, entries[1].EnvOverrides)
+	require.Equal(t, "entry[1]/entry[0]", entries[3].String())
+	require.Equal(t, map[string]string{gravita.SettingMaxConcurrency: "4"}, entries[3].Settings)
+}
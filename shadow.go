@@ -0,0 +1,217 @@
+package gravita
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ResultComparator reports whether the results of a row by the primary and the candidate are equal
+type ResultComparator func(primary, candidate interface{}) bool
+
+// EqualJSONResults is the default ResultComparator, comparing the JSON encodings of the results
+// so that the numbers of different Go types are equal as they are in the output to Redshift.
+func EqualJSONResults(primary, candidate interface{}) bool {
+	p, err := json.Marshal(primary)
+	if err != nil {
+		return reflect.DeepEqual(primary, candidate)
+	}
+	c, err := json.Marshal(candidate)
+	if err != nil {
+		return false
+	}
+	var pv, cv interface{}
+	if json.Unmarshal(p, &pv) != nil || json.Unmarshal(c, &cv) != nil {
+		return string(p) == string(c)
+	}
+	return reflect.DeepEqual(pv, cv)
+}
+
+// ShadowMismatch is a sample of the row whose results differ between the primary and the candidate
+type ShadowMismatch struct {
+	Time      time.Time
+	Metadata  LambdaUDFEventMetadata
+	Row       int
+	Arguments []interface{}
+	Primary   interface{}
+	Candidate interface{}
+	// CandidateError is set if the candidate failed, then Row is -1
+	CandidateError string
+}
+
+// ShadowStats is the result of the shadow executions
+type ShadowStats struct {
+	// Invocations is the number of the candidate executions
+	Invocations int64
+	// Skipped is the number of invocations not shadowed because MaxInFlight candidates were running
+	Skipped int64
+	// Errors is the number of the candidate executions that failed or exceeded Timeout
+	Errors int64
+	// Rows is the number of compared rows
+	Rows int64
+	// Mismatches is the number of rows whose results differ
+	Mismatches int64
+	// Samples are the first MaxSamples mismatches and errors
+	Samples []ShadowMismatch
+}
+
+// ShadowHandler is a LambdaUDFHandler that returns the results of the primary handler,
+// and runs the candidate handler on the same arguments asynchronously to compare the results row by row.
+// Note that the Lambda execution environment may be frozen after returning the response, so that
+// candidate executions can be delayed to the next invocation. Call Wait to finish them.
+type ShadowHandler struct {
+	// Comparator compares the results of each row. Default is EqualJSONResults
+	Comparator ResultComparator
+	// Timeout is the budget of each candidate execution. 0 means no timeout
+	Timeout time.Duration
+	// MaxInFlight is the max number of candidate executions running at the same time. 0 means unlimited.
+	// A candidate execution exceeding Timeout is counted until it actually returns
+	MaxInFlight int
+	// MaxSamples is the max number of samples kept in ShadowStats
+	MaxSamples int
+	Logger     Logger
+
+	primary   LambdaUDFHandler
+	candidate LambdaUDFHandler
+	inFlight  int64
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	stats     ShadowStats
+}
+
+// NewShadowHandler returns a ShadowHandler with 10 seconds Timeout, 10 MaxInFlight and 10 MaxSamples
+func NewShadowHandler(primary, candidate LambdaUDFHandler) *ShadowHandler {
+	return &ShadowHandler{
+		Comparator:  EqualJSONResults,
+		Timeout:     10 * time.Second,
+		MaxInFlight: 10,
+		MaxSamples:  10,
+		primary:     primary,
+		candidate:   candidate,
+	}
+}
+
+func (h *ShadowHandler) ExecuteUDF(ctx context.Context, args [][]interface{}) ([]interface{}, error) {
+	shadowArgs := make([][]interface{}, len(args))
+	for i, rowArgs := range args {
+		shadowArgs[i] = append([]interface{}(nil), rowArgs...)
+	}
+	results, err := h.primary.ExecuteUDF(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	if n := atomic.AddInt64(&h.inFlight, 1); h.MaxInFlight > 0 && n > int64(h.MaxInFlight) {
+		atomic.AddInt64(&h.inFlight, -1)
+		h.mu.Lock()
+		h.stats.Skipped++
+		h.mu.Unlock()
+		return results, nil
+	}
+	primaryResults := append([]interface{}(nil), results...)
+	metadata := Metadata(ctx)
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		h.shadow(metadata, shadowArgs, primaryResults)
+	}()
+	return results, nil
+}
+
+func (h *ShadowHandler) shadow(metadata *LambdaUDFEventMetadata, args [][]interface{}, primaryResults []interface{}) {
+	ctx := WithMetadata(context.Background(), metadata)
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	candidateResults, err := h.executeCandidate(ctx, args)
+	now := time.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stats.Invocations++
+	if err != nil {
+		h.stats.Errors++
+		h.addSample(ShadowMismatch{Time: now, Metadata: *metadata, Row: -1, CandidateError: err.Error()})
+		logf(h.Logger, "[warn] gravita: shadow external_function=%s query_id=%d: candidate failed: %v", metadata.ExternalFunction, metadata.QueryID, err)
+		return
+	}
+	comparator := h.Comparator
+	if comparator == nil {
+		comparator = EqualJSONResults
+	}
+	var mismatches int
+	for i := range args {
+		p, c := resultAt(primaryResults, i), resultAt(candidateResults, i)
+		h.stats.Rows++
+		if comparator(p, c) {
+			continue
+		}
+		mismatches++
+		h.stats.Mismatches++
+		h.addSample(ShadowMismatch{Time: now, Metadata: *metadata, Row: i, Arguments: args[i], Primary: p, Candidate: c})
+	}
+	if mismatches > 0 {
+		logf(h.Logger, "[warn] gravita: shadow external_function=%s query_id=%d: %d/%d rows mismatched", metadata.ExternalFunction, metadata.QueryID, mismatches, len(args))
+	}
+}
+
+// executeCandidate runs the candidate, giving up when ctx is done.
+// The in-flight slot is released when the candidate returns, even if it is given up.
+// A panic of the candidate is returned as an error, so that it does not crash the process after the primary has replied.
+func (h *ShadowHandler) executeCandidate(ctx context.Context, args [][]interface{}) ([]interface{}, error) {
+	type result struct {
+		results []interface{}
+		err     error
+	}
+	ch := make(chan result, 1)
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		defer atomic.AddInt64(&h.inFlight, -1)
+		defer func() {
+			if v := recover(); v != nil {
+				ch <- result{err: fmt.Errorf("panic: %v", v)}
+			}
+		}()
+		results, err := h.candidate.ExecuteUDF(ctx, args)
+		ch <- result{results: results, err: err}
+	}()
+	select {
+	case r := <-ch:
+		return r.results, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (h *ShadowHandler) addSample(m ShadowMismatch) {
+	if len(h.stats.Samples) < h.MaxSamples {
+		h.stats.Samples = append(h.stats.Samples, m)
+	}
+}
+
+func resultAt(results []interface{}, i int) interface{} {
+	if i < len(results) {
+		return results[i]
+	}
+	return nil
+}
+
+// Wait waits for the running candidate executions, including those given up by Timeout
+func (h *ShadowHandler) Wait() {
+	h.wg.Wait()
+}
+
+// Stats returns the result of the finished shadow executions
+func (h *ShadowHandler) Stats() ShadowStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stats := h.stats
+	stats.Samples = append([]ShadowMismatch(nil), h.stats.Samples...)
+	return stats
+}
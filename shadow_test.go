@@ -0,0 +1,132 @@
+package gravita_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadowHandler(t *testing.T) {
+	upper := gravita.LambdaUDFHandlerFunc(func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+		results := make([]interface{}, len(args))
+		for i, rowArgs := range args {
+			results[i] = strings.ToUpper(rowArgs[0].(string))
+		}
+		return results, nil
+	})
+	buggy := gravita.LambdaUDFHandlerFunc(func(ctx context.Context, args [][]interface{}) ([]interface{}, error) {
+		if gravita.Metadata(ctx).QueryID == 2 {
+			return nil, errors.New("candidate failed")
+		}
+		results := make([]interface{}, len(args))
+		for i, rowArgs := range args {
+			s := rowArgs[0].(string)
+			if s == "b" {
+				results[i] = s
+				continue
+			}
+			results[i] = strings.ToUpper(s)
+		}
+		return results, nil
+	})
+	shadow := gravita.NewShadowHandler(upper, buggy)
+	shadow.Logger = log.New(io.Discard, "", 0)
+	mux := gravita.NewMux()
+	mux.Handle("upper", shadow)
+
+	event := testLambdaUDFEvent("upper", [][]interface{}{{"a"}, {"b"}, {"c"}})
+	actual, err := mux.HandleLambdaEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"success":true,"num_records":3,"results":["A","B","C"]}`, actual)
+	shadow.Wait()
+	event.QueryID = 2
+	_, err = mux.HandleLambdaEvent(context.Background(), event)
+	require.NoError(t, err)
+	shadow.Wait()
+
+	stats := shadow.Stats()
+	require.EqualValues(t, 2, stats.Invocations)
+	require.EqualValues(t, 1, stats.Errors)
+	require.EqualValues(t, 3, stats.Rows)
+	require.EqualValues(t, 1, stats.Mismatches)
+	require.Len(t, stats.Samples, 2)
+	require.Equal(t, 1, stats.Samples[0].Row)
+	require.Equal(t, []interface{}{"b"}, stats.Samples[0].Arguments)
+	require.Equal(t, "B", stats.Samples[0].Primary)
+	require.Equal(t, "b", stats.Samples[0].Candidate)
+	require.Equal(t, "candidate failed", stats.Samples[1].CandidateError)
+}
+
+func TestShadowHandlerBudget(t *testing.T) {
+	release := make(chan struct{})
+	primary := gravita.LambdaUDFHandlerFunc(func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+		return []interface{}{1}, nil
+	})
+	slow := gravita.LambdaUDFHandlerFunc(func(ctx context.Context, args [][]interface{}) ([]interface{}, error) {
+		<-release
+		return []interface{}{1}, nil
+	})
+	shadow := gravita.NewShadowHandler(primary, slow)
+	shadow.Logger = log.New(io.Discard, "", 0)
+	shadow.MaxInFlight = 1
+	shadow.Timeout = 50 * time.Millisecond
+	invoke := func() {
+		t.Helper()
+		results, err := shadow.ExecuteUDF(context.Background(), [][]interface{}{{1}})
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{1}, results)
+	}
+	invoke()
+	invoke()
+	require.Eventually(t, func() bool {
+		return shadow.Stats().Errors == 1
+	}, time.Second, 10*time.Millisecond)
+	invoke()
+	stats := shadow.Stats()
+	require.EqualValues(t, 2, stats.Skipped, "the candidate exceeding Timeout holds the slot until it returns")
+	require.Equal(t, context.DeadlineExceeded.Error(), stats.Samples[0].CandidateError)
+
+	close(release)
+	shadow.Wait()
+	invoke()
+	shadow.Wait()
+	stats = shadow.Stats()
+	require.EqualValues(t, 2, stats.Invocations)
+	require.EqualValues(t, 2, stats.Skipped)
+	require.EqualValues(t, 1, stats.Errors)
+}
+
+func TestShadowHandlerCandidatePanic(t *testing.T) {
+	primary := gravita.LambdaUDFHandlerFunc(func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+		return []interface{}{1}, nil
+	})
+	panicky := gravita.LambdaUDFHandlerFunc(func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+		panic("boom")
+	})
+	shadow := gravita.NewShadowHandler(primary, panicky)
+	shadow.Logger = log.New(io.Discard, "", 0)
+	results, err := shadow.ExecuteUDF(context.Background(), [][]interface{}{{1}})
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{1}, results)
+	shadow.Wait()
+
+	stats := shadow.Stats()
+	require.EqualValues(t, 1, stats.Invocations)
+	require.EqualValues(t, 1, stats.Errors)
+	require.Len(t, stats.Samples, 1)
+	require.Equal(t, "panic: boom", stats.Samples[0].CandidateError)
+}
+
+func TestEqualJSONResults(t *testing.T) {
+	require.True(t, gravita.EqualJSONResults(1, 1.0))
+	require.True(t, gravita.EqualJSONResults(map[string]interface{}{"a": int64(1)}, map[string]interface{}{"a": 1}))
+	require.False(t, gravita.EqualJSONResults("1", 1))
+	require.False(t, gravita.EqualJSONResults(nil, 0))
+}
@@ -0,0 +1,270 @@
+package gravita
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// SQLType represents a Redshift data type
+type SQLType string
+
+// Redshift data types available in external functions
+const (
+	SQLSmallInt        SQLType = "SMALLINT"
+	SQLInteger         SQLType = "INTEGER"
+	SQLBigInt          SQLType = "BIGINT"
+	SQLDecimal         SQLType = "DECIMAL"
+	SQLReal            SQLType = "REAL"
+	SQLDoublePrecision SQLType = "DOUBLE PRECISION"
+	SQLBoolean         SQLType = "BOOLEAN"
+	SQLChar            SQLType = "CHAR"
+	SQLVarchar         SQLType = "VARCHAR"
+	SQLDate            SQLType = "DATE"
+	SQLTimestamp       SQLType = "TIMESTAMP"
+	SQLTimestampTZ     SQLType = "TIMESTAMPTZ"
+	SQLTime            SQLType = "TIME"
+	SQLTimeTZ          SQLType = "TIMETZ"
+	SQLSuper           SQLType = "SUPER"
+)
+
+const notNullSuffix = " NOT NULL"
+
+// NotNull returns the type that does not accept NULL.
+// Mux rejects events that have NULL in arguments of such a type
+func (t SQLType) NotNull() SQLType {
+	if t.IsNotNull() {
+		return t
+	}
+	return t + notNullSuffix
+}
+
+// IsNotNull reports whether the type does not accept NULL
+func (t SQLType) IsNotNull() bool {
+	return strings.HasSuffix(strings.ToUpper(string(t)), notNullSuffix)
+}
+
+// Base returns the type without NOT NULL
+func (t SQLType) Base() SQLType {
+	if !t.IsNotNull() {
+		return t
+	}
+	return t[:len(t)-len(notNullSuffix)]
+}
+
+// JSONType returns the JSON type in which Redshift passes values of the type
+func (t SQLType) JSONType() JSONType {
+	name := strings.ToUpper(strings.TrimSpace(string(t.Base())))
+	if i := strings.IndexRune(name, '('); i >= 0 {
+		name = strings.TrimSpace(name[:i])
+	}
+	switch name {
+	case "SMALLINT", "INT2", "INTEGER", "INT", "INT4", "BIGINT", "INT8",
+		"REAL", "FLOAT4", "DOUBLE PRECISION", "FLOAT8", "FLOAT":
+		return JSONNumber
+	case "BOOLEAN", "BOOL":
+		return JSONBoolean
+	case "DECIMAL", "NUMERIC",
+		"CHAR", "CHARACTER", "NCHAR", "BPCHAR", "VARCHAR", "CHARACTER VARYING", "NVARCHAR", "TEXT",
+		"DATE", "TIMESTAMP", "TIMESTAMP WITHOUT TIME ZONE", "TIMESTAMPTZ", "TIMESTAMP WITH TIME ZONE",
+		"TIME", "TIME WITHOUT TIME ZONE", "TIMETZ", "TIME WITH TIME ZONE":
+		return JSONString
+	}
+	return JSONAny
+}
+
+func (t SQLType) isInteger() bool {
+	switch strings.ToUpper(strings.TrimSpace(string(t.Base()))) {
+	case "SMALLINT", "INT2", "INTEGER", "INT", "INT4", "BIGINT", "INT8":
+		return true
+	}
+	return false
+}
+
+// Volatility represents the volatility of an external function
+type Volatility string
+
+// Volatilities of external function
+const (
+	Volatile  Volatility = "VOLATILE"
+	Stable    Volatility = "STABLE"
+	Immutable Volatility = "IMMUTABLE"
+)
+
+// Signature is the SQL signature of an external function
+type Signature struct {
+	// Name is the SQL function name. if empty, the exact name given to Entry.ExternalFunction is used
+	Name       string
+	Arguments  []SQLType
+	Returns    SQLType
+	Volatility Volatility
+}
+
+// Validate checks that args conform to the signature: arity, JSON type and nullability of each argument
+func (sig *Signature) Validate(args [][]interface{}) error {
+	for i, rowArgs := range args {
+		if len(rowArgs) != len(sig.Arguments) {
+			return fmt.Errorf("row %d: expected %d arguments, got %d", i+1, len(sig.Arguments), len(rowArgs))
+		}
+		for j, v := range rowArgs {
+			t := sig.Arguments[j]
+			if v == nil {
+				if t.IsNotNull() {
+					return fmt.Errorf("row %d: argument $%d must not be NULL (%s)", i+1, j+1, t)
+				}
+				continue
+			}
+			expected := t.JSONType()
+			actual := JSONTypeOf(v)
+			if !expected.Accepts(actual) {
+				return fmt.Errorf("row %d: argument $%d expected %s (%s), got %s", i+1, j+1, t.Base(), expected, actual)
+			}
+			if t.isInteger() && !isIntegral(v) {
+				return fmt.Errorf("row %d: argument $%d expected %s (integral number), got %v", i+1, j+1, t.Base(), v)
+			}
+		}
+	}
+	return nil
+}
+
+func isIntegral(v interface{}) bool {
+	switch n := v.(type) {
+	case float64:
+		return n == math.Trunc(n)
+	case float32:
+		return float64(n) == math.Trunc(float64(n))
+	case json.Number:
+		_, err := n.Int64()
+		return err == nil
+	}
+	return true
+}
+
+// Signature declares the SQL signature of the external function handled by this Entry.
+// Mux validates the arguments of each event against it before calling the handler
+func (e *Entry) Signature(sig Signature) *Entry {
+	args := make([]SQLType, len(sig.Arguments))
+	copy(args, sig.Arguments)
+	sig.Arguments = args
+	return e.update(func(s *entryState) {
+		s.signature = &sig
+	})
+}
+
+// GetSignature returns the Signature declared in the Entry, or nil
+func (e *Entry) GetSignature() *Signature {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.signature
+}
+
+func (e *Entry) functionName() string {
+	if e.signature != nil && e.signature.Name != "" {
+		return e.signature.Name
+	}
+	for _, m := range e.matchers {
+		if m, ok := m.(*externalFunctionMatcher); ok && m.glob == nil {
+			return m.pattern
+		}
+	}
+	return ""
+}
+
+// DDLOptions is the options of CREATE EXTERNAL FUNCTION statements
+type DDLOptions struct {
+	// LambdaName is the name of the Lambda function. required
+	LambdaName string
+	// IAMRole is the ARN of the IAM role. if empty, `default` is used
+	IAMRole string
+	// RetryTimeout is RETRY_TIMEOUT in milliseconds. omitted if 0
+	RetryTimeout int
+	// MaxBatchRows is MAX_BATCH_ROWS. omitted if 0
+	MaxBatchRows int
+	// MaxBatchSize is MAX_BATCH_SIZE, e.g. "512 KB". omitted if empty
+	MaxBatchSize string
+}
+
+// WriteDDL writes CREATE OR REPLACE EXTERNAL FUNCTION statements for every Entry that declares a Signature,
+// including the entries of a child Mux created by Group or Mount. The function names of a mounted child are prefixed.
+func (mux *Mux) WriteDDL(w io.Writer, opts DDLOptions) error {
+	if opts.LambdaName == "" {
+		return fmt.Errorf("lambda name is required")
+	}
+	return mux.writeDDL(w, "", "", opts)
+}
+
+func (mux *Mux) writeDDL(w io.Writer, path string, prefix string, opts DDLOptions) error {
+	for i, e := range mux.snapshot() {
+		if child, p := e.delegate(); child != nil {
+			if err := child.writeDDL(w, childPath(path, i), prefix+p, opts); err != nil {
+				return err
+			}
+		}
+		if e.signature == nil {
+			continue
+		}
+		name := e.functionName()
+		if name == "" {
+			return fmt.Errorf("%sentry[%d]: function name is unknown, set Signature.Name or an exact external function name", path, i)
+		}
+		if prefix != "" {
+			n := ParseExternalFunction(name)
+			n.Function = prefix + n.Function
+			name = n.String()
+		}
+		if e.signature.Returns == "" {
+			return fmt.Errorf("%sentry[%d]: Signature.Returns of `%s` is required", path, i, name)
+		}
+		if _, err := io.WriteString(w, createExternalFunction(name, e.signature, opts)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DDL returns CREATE OR REPLACE EXTERNAL FUNCTION statements for every Entry that declares a Signature
+func (mux *Mux) DDL(opts DDLOptions) (string, error) {
+	var b strings.Builder
+	if err := mux.WriteDDL(&b, opts); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func createExternalFunction(name string, sig *Signature, opts DDLOptions) string {
+	args := make([]string, 0, len(sig.Arguments))
+	for _, arg := range sig.Arguments {
+		args = append(args, string(arg.Base()))
+	}
+	volatility := sig.Volatility
+	if volatility == "" {
+		volatility = Volatile
+	}
+	iamRole := "default"
+	if opts.IAMRole != "" {
+		iamRole = quoteLiteral(opts.IAMRole)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE OR REPLACE EXTERNAL FUNCTION %s(%s)\n", name, strings.Join(args, ", "))
+	fmt.Fprintf(&b, "RETURNS %s\n", sig.Returns.Base())
+	fmt.Fprintf(&b, "%s\n", volatility)
+	fmt.Fprintf(&b, "LAMBDA %s\n", quoteLiteral(opts.LambdaName))
+	fmt.Fprintf(&b, "IAM_ROLE %s", iamRole)
+	if opts.RetryTimeout > 0 {
+		fmt.Fprintf(&b, "\nRETRY_TIMEOUT %d", opts.RetryTimeout)
+	}
+	if opts.MaxBatchRows > 0 {
+		fmt.Fprintf(&b, "\nMAX_BATCH_ROWS %d", opts.MaxBatchRows)
+	}
+	if opts.MaxBatchSize != "" {
+		fmt.Fprintf(&b, "\nMAX_BATCH_SIZE %s", opts.MaxBatchSize)
+	}
+	b.WriteString(";\n")
+	return b.String()
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,105 @@
+package gravita_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDDL(t *testing.T) {
+	noop := func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	mux := gravita.NewMux()
+	mux.HandleRowFunc("mask_email", noop).Signature(gravita.Signature{
+		Arguments:  []gravita.SQLType{gravita.SQLVarchar.NotNull()},
+		Returns:    gravita.SQLVarchar.NotNull(),
+		Volatility: gravita.Immutable,
+	})
+	mux.HandleRowFunc("*_concat", noop).Signature(gravita.Signature{
+		Name:      "analytics.f_concat",
+		Arguments: []gravita.SQLType{gravita.SQLVarchar, gravita.SQLInteger},
+		Returns:   gravita.SQLVarchar,
+	})
+	mux.HandleRowFunc("*", noop)
+
+	actual, err := mux.DDL(gravita.DDLOptions{
+		LambdaName:   "udf-function",
+		IAMRole:      "arn:aws:iam::123456789012:role/redshift-udf",
+		MaxBatchRows: 100,
+	})
+	require.NoError(t, err)
+	expected := `CREATE OR REPLACE EXTERNAL FUNCTION mask_email(VARCHAR)
+RETURNS VARCHAR
+IMMUTABLE
+LAMBDA 'udf-function'
+IAM_ROLE 'arn:aws:iam::123456789012:role/redshift-udf'
+MAX_BATCH_ROWS 100;
+CREATE OR REPLACE EXTERNAL FUNCTION analytics.f_concat(VARCHAR, INTEGER)
+RETURNS VARCHAR
+VOLATILE
+LAMBDA 'udf-function'
+IAM_ROLE 'arn:aws:iam::123456789012:role/redshift-udf'
+MAX_BATCH_ROWS 100;
+`
+	require.Equal(t, expected, actual)
+
+	mux.HandleRowFunc("*_upper", noop).Signature(gravita.Signature{Returns: gravita.SQLVarchar})
+	_, err = mux.DDL(gravita.DDLOptions{LambdaName: "udf-function"})
+	require.EqualError(t, err, "entry[3]: function name is unknown, set Signature.Name or an exact external function name")
+
+	mux = gravita.NewMux()
+	mux.HandleRowFunc("f_upper", noop).Signature(gravita.Signature{Arguments: []gravita.SQLType{gravita.SQLVarchar}})
+	_, err = mux.DDL(gravita.DDLOptions{LambdaName: "udf-function"})
+	require.EqualError(t, err, "entry[0]: Signature.Returns of `f_upper` is required")
+}
+
+func TestSignatureValidation(t *testing.T) {
+	cases := []struct {
+		casename string
+		callArgs [][]interface{}
+		expected string
+	}{
+		{
+			casename: "valid",
+			callArgs: [][]interface{}{{"hoge", 1.0, "1.50"}, {nil, 2, nil}},
+			expected: `{"success":true,"num_records":2,"results":["ok","ok"]}`,
+		},
+		{
+			casename: "arity",
+			callArgs: [][]interface{}{{"hoge", 1, "1.50"}, {"fuga", 2}},
+			expected: "{\"success\":false,\"error_msg\":\"external function `test_udf`: row 2: expected 3 arguments, got 2\"}",
+		},
+		{
+			casename: "type",
+			callArgs: [][]interface{}{{"hoge", "1", "1.50"}},
+			expected: "{\"success\":false,\"error_msg\":\"external function `test_udf`: row 1: argument $2 expected INTEGER (number), got string\"}",
+		},
+		{
+			casename: "integral",
+			callArgs: [][]interface{}{{"hoge", 1.5, "1.50"}},
+			expected: "{\"success\":false,\"error_msg\":\"external function `test_udf`: row 1: argument $2 expected INTEGER (integral number), got 1.5\"}",
+		},
+		{
+			casename: "not null",
+			callArgs: [][]interface{}{{"hoge", nil, "1.50"}},
+			expected: "{\"success\":false,\"error_msg\":\"external function `test_udf`: row 1: argument $2 must not be NULL (INTEGER NOT NULL)\"}",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.casename, func(t *testing.T) {
+			mux := gravita.NewMux()
+			mux.HandleRowFunc("test_udf", func(_ context.Context, _ []interface{}) (interface{}, error) {
+				return "ok", nil
+			}).Signature(gravita.Signature{
+				Arguments: []gravita.SQLType{"VARCHAR(256)", gravita.SQLInteger.NotNull(), "DECIMAL(10,2)"},
+				Returns:   gravita.SQLVarchar,
+			})
+			actual, err := mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("test_udf", c.callArgs))
+			require.NoError(t, err)
+			require.JSONEq(t, c.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package gravita
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// SplitKey is the value of the event that decides the variant of SplitHandler
+type SplitKey int
+
+const (
+	// SplitByQueryID sends all invocations of one query to the same variant
+	SplitByQueryID SplitKey = iota
+	// SplitByUser sends all invocations of one user to the same variant
+	SplitByUser
+)
+
+// Variant is the handler selected by SplitHandler
+type Variant string
+
+const (
+	VariantPrimary   Variant = "primary"
+	VariantCandidate Variant = "candidate"
+)
+
+// VariantStats is the metrics of a variant of SplitHandler
+type VariantStats struct {
+	Invocations int64
+	Rows        int64
+	Errors      int64
+	Duration    time.Duration
+}
+
+type variantCounter struct {
+	invocations int64
+	rows        int64
+	errors      int64
+	duration    int64
+}
+
+func (c *variantCounter) add(rows int, err error, d time.Duration) {
+	atomic.AddInt64(&c.invocations, 1)
+	atomic.AddInt64(&c.rows, int64(rows))
+	if err != nil {
+		atomic.AddInt64(&c.errors, 1)
+	}
+	atomic.AddInt64(&c.duration, int64(d))
+}
+
+func (c *variantCounter) stats() VariantStats {
+	return VariantStats{
+		Invocations: atomic.LoadInt64(&c.invocations),
+		Rows:        atomic.LoadInt64(&c.rows),
+		Errors:      atomic.LoadInt64(&c.errors),
+		Duration:    time.Duration(atomic.LoadInt64(&c.duration)),
+	}
+}
+
+// SplitHandler is a LambdaUDFHandler that routes a percentage of invocations to a candidate handler.
+// The variant is decided by the hash of the QueryID or User, so that one query sees one version.
+type SplitHandler struct {
+	primary   LambdaUDFHandler
+	candidate LambdaUDFHandler
+	key       SplitKey
+	// basisPoints is the percentage multiplied by 100, accessed atomically
+	basisPoints int64
+
+	primaryCounter   variantCounter
+	candidateCounter variantCounter
+}
+
+// NewSplitHandler returns a SplitHandler routing percent (0 to 100) of invocations to candidate by QueryID
+func NewSplitHandler(primary, candidate LambdaUDFHandler, percent float64) *SplitHandler {
+	h := &SplitHandler{
+		primary:   primary,
+		candidate: candidate,
+		key:       SplitByQueryID,
+	}
+	h.SetPercent(percent)
+	return h
+}
+
+// SplitBy sets the value of the event that decides the variant. It should be called before handling events
+func (h *SplitHandler) SplitBy(key SplitKey) *SplitHandler {
+	h.key = key
+	return h
+}
+
+// SetPercent changes the percentage of invocations routed to the candidate. It is safe to call while handling events,
+// and SetPercent(0) rolls back to the primary immediately. The percentage is clamped to 0 to 100.
+func (h *SplitHandler) SetPercent(percent float64) {
+	bp := int64(math.Round(percent * 100))
+	if bp < 0 {
+		bp = 0
+	}
+	if bp > 10000 {
+		bp = 10000
+	}
+	atomic.StoreInt64(&h.basisPoints, bp)
+}
+
+// GetPercent returns the percentage of invocations routed to the candidate
+func (h *SplitHandler) GetPercent() float64 {
+	return float64(atomic.LoadInt64(&h.basisPoints)) / 100
+}
+
+// Variant returns the variant selected for the event metadata
+func (h *SplitHandler) Variant(metadata *LambdaUDFEventMetadata) Variant {
+	var key string
+	switch h.key {
+	case SplitByUser:
+		key = metadata.User
+	default:
+		key = strconv.Itoa(metadata.QueryID)
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	if int64(hash.Sum32()%10000) < atomic.LoadInt64(&h.basisPoints) {
+		return VariantCandidate
+	}
+	return VariantPrimary
+}
+
+// Stats returns the metrics of each variant
+func (h *SplitHandler) Stats() map[Variant]VariantStats {
+	return map[Variant]VariantStats{
+		VariantPrimary:   h.primaryCounter.stats(),
+		VariantCandidate: h.candidateCounter.stats(),
+	}
+}
+
+func (h *SplitHandler) ExecuteUDF(ctx context.Context, args [][]interface{}) ([]interface{}, error) {
+	handler, counter := h.primary, &h.primaryCounter
+	if h.Variant(Metadata(ctx)) == VariantCandidate {
+		handler, counter = h.candidate, &h.candidateCounter
+	}
+	startAt := time.Now()
+	results, err := handler.ExecuteUDF(ctx, args)
+	counter.add(len(args), err, time.Since(startAt))
+	return results, err
+}
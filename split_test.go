@@ -0,0 +1,62 @@
+package gravita_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitHandler(t *testing.T) {
+	constant := func(v string, err error) gravita.LambdaUDFHandler {
+		return gravita.LambdaUDFHandlerFunc(func(_ context.Context, args [][]interface{}) ([]interface{}, error) {
+			if err != nil {
+				return nil, err
+			}
+			results := make([]interface{}, len(args))
+			for i := range results {
+				results[i] = v
+			}
+			return results, nil
+		})
+	}
+	split := gravita.NewSplitHandler(constant("v1", nil), constant("v2", errors.New("v2 failed")), 30)
+	mux := gravita.NewMux()
+	mux.Handle("test_udf", split)
+
+	variants := make(map[int]string)
+	for queryID := 0; queryID < 1000; queryID++ {
+		for i := 0; i < 2; i++ {
+			event := testLambdaUDFEvent("test_udf", [][]interface{}{{1}, {2}})
+			event.QueryID = queryID
+			actual, err := mux.HandleLambdaEvent(context.Background(), event)
+			require.NoError(t, err)
+			if prev, ok := variants[queryID]; ok {
+				require.Equal(t, prev, actual, "query %d must see one version", queryID)
+			}
+			variants[queryID] = actual
+		}
+	}
+	stats := split.Stats()
+	primary, candidate := stats[gravita.VariantPrimary], stats[gravita.VariantCandidate]
+	require.EqualValues(t, 2000, primary.Invocations+candidate.Invocations)
+	require.InDelta(t, 600, candidate.Invocations, 100)
+	require.EqualValues(t, 2*candidate.Invocations, candidate.Rows)
+	require.Equal(t, candidate.Invocations, candidate.Errors)
+	require.Zero(t, primary.Errors)
+
+	os.Setenv("GRAVITA_TEST_UDF_CANARY_PERCENT", "0")
+	defer os.Unsetenv("GRAVITA_TEST_UDF_CANARY_PERCENT")
+	require.NoError(t, mux.ApplyEnvSettings())
+	require.Equal(t, map[string]string{gravita.SettingCanaryPercent: "0"}, mux.Entries()[0].Settings)
+	for queryID := 0; queryID < 100; queryID++ {
+		require.Equal(t, gravita.VariantPrimary, split.Variant(&gravita.LambdaUDFEventMetadata{QueryID: queryID}))
+	}
+
+	split.SetPercent(100)
+	split.SplitBy(gravita.SplitByUser)
+	require.Equal(t, gravita.VariantCandidate, split.Variant(&gravita.LambdaUDFEventMetadata{User: "etl"}))
+}
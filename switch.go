@@ -0,0 +1,240 @@
+package gravita
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables read by SwitchBoard.LoadEnv.
+// GRAVITA_DISABLED and GRAVITA_MAINTENANCE are comma separated lists of entry names or external function names.
+const (
+	DisabledEnv           = "GRAVITA_DISABLED"
+	DisabledMessageEnv    = "GRAVITA_DISABLED_MESSAGE"
+	MaintenanceEnv        = "GRAVITA_MAINTENANCE"
+	MaintenanceMessageEnv = "GRAVITA_MAINTENANCE_MESSAGE"
+)
+
+// SwitchState is the state of an external function in SwitchBoard
+type SwitchState int
+
+const (
+	SwitchEnabled SwitchState = iota
+	SwitchDisabled
+	SwitchMaintenance
+)
+
+func (s SwitchState) String() string {
+	switch s {
+	case SwitchEnabled:
+		return "enabled"
+	case SwitchDisabled:
+		return "disabled"
+	case SwitchMaintenance:
+		return "maintenance"
+	}
+	return fmt.Sprintf("SwitchState(%d)", int(s))
+}
+
+func (s SwitchState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *SwitchState) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "enabled":
+		*s = SwitchEnabled
+	case "disabled":
+		*s = SwitchDisabled
+	case "maintenance":
+		*s = SwitchMaintenance
+	default:
+		return fmt.Errorf("unknown switch state `%s`", text)
+	}
+	return nil
+}
+
+// Switch is the state of an external function with the message returned to the caller
+type Switch struct {
+	State SwitchState `json:"state"`
+	// Message is returned as error_msg of blocked invocations. If empty, the message of SwitchBoard is used
+	Message string `json:"message,omitempty"`
+}
+
+// SwitchBoard is a kill switch of entries. Set it to Mux.Switches.
+// Switches are keyed by entry name or external function name, and are set by the API, a file and environment variables
+// in the order of precedence.
+type SwitchBoard struct {
+	// DisabledMessage is the default message of disabled functions, `%s` is replaced by the external function name
+	DisabledMessage string
+	// MaintenanceMessage is the default message of functions in maintenance, `%s` is replaced by the external function name
+	MaintenanceMessage string
+	Logger             Logger
+
+	mu      sync.RWMutex
+	api     map[string]Switch
+	file    map[string]Switch
+	env     map[string]Switch
+	blocked map[string]int64
+}
+
+func NewSwitchBoard() *SwitchBoard {
+	return &SwitchBoard{
+		DisabledMessage:    "external function `%s` is disabled",
+		MaintenanceMessage: "external function `%s` is under maintenance",
+		api:                make(map[string]Switch),
+		file:               make(map[string]Switch),
+		env:                make(map[string]Switch),
+		blocked:            make(map[string]int64),
+	}
+}
+
+// Disable blocks the invocations of the key. If message is empty, DisabledMessage is used
+func (sb *SwitchBoard) Disable(key string, message string) {
+	sb.set(key, Switch{State: SwitchDisabled, Message: message})
+}
+
+// Maintenance blocks the invocations of the key as under maintenance. If message is empty, MaintenanceMessage is used
+func (sb *SwitchBoard) Maintenance(key string, message string) {
+	sb.set(key, Switch{State: SwitchMaintenance, Message: message})
+}
+
+// Enable enables the key, overriding the file and environment variables
+func (sb *SwitchBoard) Enable(key string) {
+	sb.set(key, Switch{State: SwitchEnabled})
+}
+
+// Reset removes the switch of the key set by the API, so that the file and environment variables take effect again
+func (sb *SwitchBoard) Reset(key string) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	delete(sb.api, key)
+}
+
+func (sb *SwitchBoard) set(key string, s Switch) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.api[key] = s
+}
+
+// Get returns the effective switch of the key
+func (sb *SwitchBoard) Get(key string) Switch {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+	return sb.getLocked(key)
+}
+
+func (sb *SwitchBoard) getLocked(key string) Switch {
+	for _, m := range []map[string]Switch{sb.api, sb.file, sb.env} {
+		if s, ok := m[key]; ok {
+			return s
+		}
+	}
+	return Switch{State: SwitchEnabled}
+}
+
+// Blocked returns the number of blocked invocations per key
+func (sb *SwitchBoard) Blocked() map[string]int64 {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+	blocked := make(map[string]int64, len(sb.blocked))
+	for k, v := range sb.blocked {
+		blocked[k] = v
+	}
+	return blocked
+}
+
+// LoadEnv reads GRAVITA_DISABLED, GRAVITA_MAINTENANCE and their messages, replacing the previously loaded values
+func (sb *SwitchBoard) LoadEnv() {
+	env := make(map[string]Switch)
+	for _, v := range []struct {
+		name    string
+		message string
+		state   SwitchState
+	}{
+		{MaintenanceEnv, MaintenanceMessageEnv, SwitchMaintenance},
+		{DisabledEnv, DisabledMessageEnv, SwitchDisabled},
+	} {
+		for _, key := range strings.Split(os.Getenv(v.name), ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				env[key] = Switch{State: v.state, Message: os.Getenv(v.message)}
+			}
+		}
+	}
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.env = env
+}
+
+// LoadFile reads the switches from a JSON file such as {"geocode": {"state": "maintenance", "message": "back at 10:00 UTC"}},
+// replacing the previously loaded values. A missing file means no switches.
+func (sb *SwitchBoard) LoadFile(path string) error {
+	file := make(map[string]Switch)
+	bs, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(bs, &file); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.file = file
+	return nil
+}
+
+// WatchFile loads the file and re-reads it every interval until ctx is done.
+// Errors of re-reading are logged and the previous switches are kept.
+func (sb *SwitchBoard) WatchFile(ctx context.Context, path string, interval time.Duration) error {
+	if err := sb.LoadFile(path); err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := sb.LoadFile(path); err != nil {
+					logf(sb.Logger, "[warn] gravita: failed to reload switches: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// check returns the error of a blocked invocation, or nil if the invocation is enabled.
+// The entry name is checked before the external function name.
+func (sb *SwitchBoard) check(entry *Entry, exFunc string) error {
+	for _, key := range []string{entry.name, exFunc} {
+		if key == "" {
+			continue
+		}
+		s := sb.Get(key)
+		if s.State == SwitchEnabled {
+			continue
+		}
+		sb.mu.Lock()
+		sb.blocked[key]++
+		sb.mu.Unlock()
+		msg := s.Message
+		if msg == "" && s.State == SwitchMaintenance {
+			msg = sb.MaintenanceMessage
+		}
+		if msg == "" {
+			msg = sb.DisabledMessage
+		}
+		return errors.New(strings.ReplaceAll(msg, "%s", exFunc))
+	}
+	return nil
+}
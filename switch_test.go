@@ -0,0 +1,79 @@
+package gravita_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuxSwitches(t *testing.T) {
+	os.Setenv(gravita.MaintenanceEnv, "mask, unused")
+	defer os.Unsetenv(gravita.MaintenanceEnv)
+	path := filepath.Join(t.TempDir(), "switches.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"geocode": {"state": "disabled", "message": "geocode API is down"}}`), 0644))
+
+	sb := gravita.NewSwitchBoard()
+	sb.LoadEnv()
+	require.NoError(t, sb.LoadFile(path))
+	mux := gravita.NewMux()
+	mux.Switches = sb
+	echo := func(_ context.Context, args []interface{}) (interface{}, error) {
+		return args[0], nil
+	}
+	mux.HandleRowFunc("geocode", echo)
+	mux.HandleRowFunc("mask_*", echo).Name("mask")
+	mux.HandleRowFunc("*", echo)
+
+	invoke := func(exFunc string) string {
+		t.Helper()
+		actual, err := mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent(exFunc, [][]interface{}{{1}}))
+		require.NoError(t, err)
+		return actual
+	}
+	require.JSONEq(t, `{"success":false,"error_msg":"geocode API is down"}`, invoke("geocode"))
+	require.JSONEq(t, `{"success":false,"error_msg":"external function `+"`mask_email`"+` is under maintenance"}`, invoke("mask_email"))
+	require.JSONEq(t, `{"success":true,"num_records":1,"results":[1]}`, invoke("concat"))
+
+	sb.Disable("concat", "")
+	require.JSONEq(t, `{"success":false,"error_msg":"external function `+"`concat`"+` is disabled"}`, invoke("concat"))
+	sb.Enable("mask")
+	require.JSONEq(t, `{"success":true,"num_records":1,"results":[1]}`, invoke("mask_email"))
+	sb.Reset("mask")
+	require.Equal(t, gravita.SwitchMaintenance, sb.Get("mask").State)
+
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, sb.LoadFile(path))
+	require.JSONEq(t, `{"success":true,"num_records":1,"results":[1]}`, invoke("geocode"))
+
+	require.Equal(t, map[string]int64{"geocode": 1, "mask": 1, "concat": 1}, sb.Blocked())
+}
+
+func TestMuxSwitchesBeforeChecks(t *testing.T) {
+	sb := gravita.NewSwitchBoard()
+	sb.Disable("geocode", "geocode API is down")
+	mux := gravita.NewMux()
+	mux.Switches = sb
+	entry := mux.HandleRowFunc("geocode", func(_ context.Context, args []interface{}) (interface{}, error) {
+		return args[0], nil
+	}).
+		Signature(gravita.Signature{Arguments: []gravita.SQLType{gravita.SQLInteger}, Returns: gravita.SQLInteger}).
+		Deprecated("use geocode_v2 instead", time.Now().Add(-time.Hour))
+
+	actual, err := mux.HandleLambdaEvent(context.Background(), testLambdaUDFEvent("geocode", [][]interface{}{{"tokyo"}}))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"success":false,"error_msg":"geocode API is down"}`, actual)
+	require.Empty(t, entry.GetDeprecation().Usage(), "blocked invocations are not counted as usage")
+}
+
+func TestSwitchBoardLoadFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "switches.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"geocode": {"state": "off"}}`), 0644))
+	err := gravita.NewSwitchBoard().LoadFile(path)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown switch state `off`")
+}
@@ -0,0 +1,227 @@
+package gravita
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Err returns the errors occurred while registering the Entry, such as invalid patterns
+func (e *Entry) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch len(e.errs) {
+	case 0:
+		return nil
+	case 1:
+		return e.errs[0]
+	}
+	msgs := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Errorf("%s", strings.Join(msgs, "; "))
+}
+
+// ValidationIssue is a problem of routing found by Mux.ValidationIssues
+type ValidationIssue struct {
+	// Path is the path of the child Mux that the Entry is registered in, see EntryDescriptor.Path
+	Path string
+	// Entry is the index of the Entry in registration order
+	Entry int
+	// Warning is true if the issue does not prevent routing, such as overlapping entries
+	Warning bool
+	Message string
+}
+
+func (issue ValidationIssue) String() string {
+	return fmt.Sprintf("%sentry[%d]: %s", issue.Path, issue.Entry, issue.Message)
+}
+
+// ValidationError is returned by Mux.Validate
+type ValidationError struct {
+	Issues []ValidationIssue
+}
+
+func (err *ValidationError) Error() string {
+	msgs := make([]string, 0, len(err.Issues))
+	for _, issue := range err.Issues {
+		msgs = append(msgs, issue.String())
+	}
+	return "invalid mux: " + strings.Join(msgs, ", ")
+}
+
+// Validate checks the registered entries before starting to handle events.
+// It returns *ValidationError if there are entries with invalid patterns added by Entry.MatcherOrError, without handler or that never match because of earlier entries.
+// Overlapping entries are not errors, they are logged as warnings.
+func (mux *Mux) Validate() error {
+	var errs []ValidationIssue
+	for _, issue := range mux.ValidationIssues() {
+		if issue.Warning {
+			mux.logf("[warn] gravita: %s", issue)
+			continue
+		}
+		errs = append(errs, issue)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Issues: errs}
+}
+
+// ValidationIssues returns all problems of the registered entries including warnings.
+// The entries of a child Mux created by Group or Mount are also checked.
+func (mux *Mux) ValidationIssues() []ValidationIssue {
+	return mux.validationIssues("", make([]ValidationIssue, 0))
+}
+
+func (mux *Mux) validationIssues(path string, issues []ValidationIssue) []ValidationIssue {
+	start := len(issues)
+	entries := mux.snapshot()
+	order := routeOrder(entries, mux.MostSpecificMatch)
+	for k, j := range order {
+		e := entries[j]
+		for _, err := range e.errs {
+			issues = append(issues, ValidationIssue{Entry: j, Message: fmt.Sprintf("invalid pattern: %v", err)})
+		}
+		if e.handler == nil {
+			issues = append(issues, ValidationIssue{Entry: j, Message: "handler is nil"})
+		}
+		if len(e.errs) > 0 || e.handler == nil {
+			continue
+		}
+		for _, i := range order[:k] {
+			prev := entries[i]
+			if len(prev.errs) > 0 || prev.handler == nil {
+				continue
+			}
+			if entryCovers(prev, e) {
+				issues = append(issues, ValidationIssue{Entry: j, Message: fmt.Sprintf("unreachable, shadowed by entry[%d]", i)})
+				break
+			}
+			if name, ok := entryOverlaps(prev, e); ok {
+				issues = append(issues, ValidationIssue{
+					Entry:   j,
+					Warning: true,
+					Message: fmt.Sprintf("overlaps entry[%d] on external function `%s`, entry[%d] is evaluated first", i, name, i),
+				})
+			}
+		}
+	}
+	for k := start; k < len(issues); k++ {
+		issues[k].Path = path
+	}
+	for i, e := range entries {
+		if child, _ := e.delegate(); child != nil {
+			issues = child.validationIssues(childPath(path, i), issues)
+		}
+	}
+	return issues
+}
+
+// entryCovers reports whether every event that matches e also matches prev.
+// It is conservative, may return false even if prev covers e.
+func entryCovers(prev, e *Entry) bool {
+	for _, pm := range prev.matchers {
+		if _, ok := pm.(matchAllMacher); ok {
+			continue
+		}
+		covered := false
+		for _, m := range e.matchers {
+			if matcherCovers(pm, m) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// entryOverlaps reports whether prev may match events of the exact external function name of e
+func entryOverlaps(prev, e *Entry) (string, bool) {
+	for _, m := range e.matchers {
+		exact, ok := m.(*externalFunctionMatcher)
+		if !ok || exact.glob != nil {
+			continue
+		}
+		for _, pm := range prev.matchers {
+			if matcherCovers(pm, exact) {
+				return exact.pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// matcherCovers reports whether every event that matches m also matches pm
+func matcherCovers(pm, m Matcher) bool {
+	if _, ok := pm.(matchAllMacher); ok {
+		return true
+	}
+	if pf, ok := pm.(*externalFunctionMatcher); ok {
+		f, ok := m.(*externalFunctionMatcher)
+		if !ok {
+			return false
+		}
+		if f.fold && !pf.fold {
+			return false
+		}
+		if f.glob != nil {
+			return pf.pattern == f.pattern
+		}
+		if f.qualified {
+			return pf.Match(&LambdaUDFEvent{LambdaUDFEventMetadata: LambdaUDFEventMetadata{ExternalFunction: f.pattern}})
+		}
+		return !pf.qualified && pf.matchName(f.pattern)
+	}
+	if pg, ok := globOf(pm); ok {
+		g, ok := globOf(m)
+		if ok && reflect.TypeOf(pm) == reflect.TypeOf(m) {
+			return pg.pattern == g.pattern
+		}
+		return false
+	}
+	if pr, ok := regexpOf(pm); ok {
+		r, ok := regexpOf(m)
+		if ok && reflect.TypeOf(pm) == reflect.TypeOf(m) {
+			return pr.String() == r.String()
+		}
+		return false
+	}
+	if reflect.TypeOf(pm) != reflect.TypeOf(m) || reflect.TypeOf(pm).Kind() == reflect.Func {
+		return false
+	}
+	return reflect.DeepEqual(pm, m)
+}
+
+func globOf(m Matcher) (*glob, bool) {
+	switch m := m.(type) {
+	case *userGlobMatcher:
+		return (*glob)(m), true
+	case *clusterGlobMatcher:
+		return (*glob)(m), true
+	case *databaseGlobMatcher:
+		return (*glob)(m), true
+	case *requestIDGlobMatcher:
+		return (*glob)(m), true
+	}
+	return nil, false
+}
+
+func regexpOf(m Matcher) (*regexp.Regexp, bool) {
+	switch m := m.(type) {
+	case *externalFunctionRegexpMatcher:
+		return (*regexp.Regexp)(m), true
+	case *userRegexpMatcher:
+		return (*regexp.Regexp)(m), true
+	case *clusterRegexpMatcher:
+		return (*regexp.Regexp)(m), true
+	case *databaseRegexpMatcher:
+		return (*regexp.Regexp)(m), true
+	}
+	return nil, false
+}
@@ -0,0 +1,71 @@
+package gravita_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/mashiike/gravita"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMatcherErrors(t *testing.T) {
+	_, err := gravita.NewExternalFunctionMatcher("udf_[0-9")
+	require.EqualError(t, err, "glob `udf_[0-9`: unterminated character class")
+	_, err = gravita.NewUserRegexpMatcher("(")
+	require.Error(t, err)
+	m, err := gravita.NewClusterMatcher("dum*")
+	require.NoError(t, err)
+	require.True(t, m.Match(testLambdaUDFEvent("test_udf", nil)))
+}
+
+func TestMuxValidate(t *testing.T) {
+	noop := func(_ context.Context, _ [][]interface{}) ([]interface{}, error) {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	mux := gravita.NewMux()
+	mux.Logger = log.New(&buf, "", 0)
+	mux.HandleFunc("concat", noop).Cluster("hoge")
+	mux.HandleFunc("conc*", noop)
+	mux.HandleFunc("concat", noop)
+	invalid := mux.NewEntry().HandlerFunc(noop).
+		MatcherOrError(gravita.NewExternalFunctionMatcher("udf_[0-9")).
+		MatcherOrError(gravita.NewClusterRegexpMatcher("("))
+	mux.NewEntry().ExternalFunction("nil_handler")
+	mux.HandleFunc("*", noop)
+	mux.HandleFunc("other", noop)
+
+	require.Error(t, invalid.Err())
+	require.False(t, invalid.Match(testLambdaUDFEvent("udf_1", nil)), "invalid entry never matches")
+
+	err := mux.Validate()
+	var verr *gravita.ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Equal(t, []gravita.ValidationIssue{
+		{Entry: 2, Message: "unreachable, shadowed by entry[1]"},
+		{Entry: 3, Message: "invalid pattern: glob `udf_[0-9`: unterminated character class"},
+		{Entry: 3, Message: "invalid pattern: error parsing regexp: missing closing ): `(`"},
+		{Entry: 4, Message: "handler is nil"},
+		{Entry: 6, Message: "unreachable, shadowed by entry[5]"},
+	}, verr.Issues)
+	require.Equal(t, "[warn] gravita: entry[2]: overlaps entry[0] on external function `concat`, entry[0] is evaluated first\n", buf.String())
+}
+
+func TestMuxValidateOK(t *testing.T) {
+	noop := func(_ context.Context, _ []interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	mux := gravita.NewMux()
+	mux.Logger = log.New(io.Discard, "", 0)
+	mux.HandleRowFunc("concat", noop).NumArguments(1)
+	mux.HandleRowFunc("concat", noop).NumArguments(2)
+	mux.HandleRowFunc("analytics.mask", noop)
+	mux.HandleRowFunc("mask", noop)
+	mux.HandleRowFunc("*", noop)
+	require.NoError(t, mux.Validate())
+	require.Len(t, mux.ValidationIssues(), 1, "overloads are reported as a warning")
+}